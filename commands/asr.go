@@ -1,40 +1,21 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/john-pettigrew/wyoming-cli/wyoming"
 )
 
-func validateInputsASR(serverAddr, inputFilePath string, inputRawData bool, inputRawDataRate, inputRawDataChannels, audioWindowMS int, soundThreshold, silenceThreshold int32, minSoundDuration, minSilenceDuration int) error {
-	if serverAddr == "" {
-		return errors.New("missing server address")
-	}
-
-	if audioWindowMS <= 0 {
-		return errors.New("audio-window-ms must be greater than 0")
-	}
-	if soundThreshold <= 0 {
-		return errors.New("sound-threshold must be greater than 0")
-	}
-	if silenceThreshold <= 0 {
-		return errors.New("silence-threshold must be greater than 0")
-	}
-	if minSoundDuration <= 0 {
-		return errors.New("min-sound-duration-ms must be greater than 0")
-	}
-	if minSoundDuration%audioWindowMS != 0 {
-		return errors.New("min-sound-duration-ms must be divisible by audio-window-ms")
-	}
-	if minSilenceDuration <= 0 {
-		return errors.New("min-silence-duration-ms must be greater than 0")
-	}
-	if minSilenceDuration%audioWindowMS != 0 {
-		return errors.New("min-silence-duration-ms must be divisible by audio-window-ms")
+func validateInputsASR(inputFilePath string, inputRawData bool, inputRawDataRate, inputRawDataChannels int, options wyoming.TranscribeOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
 	}
 
 	if inputRawData {
@@ -63,59 +44,155 @@ func validateInputsASR(serverAddr, inputFilePath string, inputRawData bool, inpu
 	return nil
 }
 
-func parseAndValidateFlagsASR(currentFlag *flag.FlagSet) (string, string, string, string, bool, int, int, int, int32, int32, int, int, int, error) {
-	serverAddr := currentFlag.String("addr", "localhost:10300", "address and port for asr Wyoming server")
+// addSegmenterFlagsASR defines the flags controlling how audio is segmented on currentFlag. The function returned
+// must be called once currentFlag has been parsed and returns the segmenter options set by the flags.
+func addSegmenterFlagsASR(currentFlag *flag.FlagSet) func() wyoming.SegmenterOptions {
+	defaults := wyoming.DefaultSegmenterOptions()
+
+	audioWindowMS := currentFlag.Int("audio-window-ms", defaults.AudioWindowMS, "window size in MS to use for detecting sound")
+	vadAddr := currentFlag.String("vad-addr", defaults.VADAddr, "address and port for a Wyoming VAD server to use instead of -vad")
+	vad := currentFlag.String("vad", defaults.VAD, "voice activity detector to use for detecting sound (peak, energy, spectral)")
+	soundThreshold := currentFlag.Int("sound-threshold", int(defaults.SoundThreshold), "level of noise for a sound event (peak)")
+	silenceThreshold := currentFlag.Int("silence-threshold", int(defaults.SilenceThreshold), "level of noise for a silence event (peak)")
+	energySoundDB := currentFlag.Float64("energy-sound-db", defaults.EnergySoundDB, "dB above the noise floor for a sound event (energy, spectral)")
+	energySilenceDB := currentFlag.Float64("energy-silence-db", defaults.EnergySilenceDB, "dB above the noise floor for a silence event (energy, spectral)")
+	spectralFlatness := currentFlag.Float64("spectral-flatness", defaults.SpectralFlatness, "highest spectral flatness for a sound event (spectral)")
+	minSoundDuration := currentFlag.Int("min-sound-duration-ms", defaults.MinSoundDurationMS, "minimum length of a sound event")
+	minSilenceDuration := currentFlag.Int("min-silence-duration-ms", defaults.MinSilenceDurationMS, "minimum length of a silence event")
+	preRollMS := currentFlag.Int("pre-roll-ms", defaults.PreRollMS, "length of audio before a sound event to include in each segment")
+	postRollMS := currentFlag.Int("post-roll-ms", defaults.PostRollMS, "length of audio after a sound event to include in each segment")
+	maxSegmentMS := currentFlag.Int("max-segment-ms", defaults.MaxSegmentMS, "maximum length of a segment before it is split at the quietest point (0 for no limit)")
+
+	return func() wyoming.SegmenterOptions {
+		return wyoming.SegmenterOptions{
+			VADAddr:              *vadAddr,
+			VAD:                  *vad,
+			AudioWindowMS:        *audioWindowMS,
+			SoundThreshold:       int32(*soundThreshold),
+			SilenceThreshold:     int32(*silenceThreshold),
+			EnergySoundDB:        *energySoundDB,
+			EnergySilenceDB:      *energySilenceDB,
+			SpectralFlatness:     *spectralFlatness,
+			MinSoundDurationMS:   *minSoundDuration,
+			MinSilenceDurationMS: *minSilenceDuration,
+			PreRollMS:            *preRollMS,
+			PostRollMS:           *postRollMS,
+			MaxSegmentMS:         *maxSegmentMS,
+		}
+	}
+}
+
+// asrCommandArgs holds the values of the asr flags.
+type asrCommandArgs struct {
+	InputFilePath        string
+	InputRawData         bool
+	InputRawDataRate     int
+	InputRawDataChannels int
+	Options              wyoming.TranscribeOptions
+}
+
+// addFlagsASR adds the asr flags. The returned function validates them after parsing.
+func addFlagsASR(currentFlag *flag.FlagSet, globals GlobalOptions) func() (asrCommandArgs, error) {
+	defaults := wyoming.DefaultTranscribeOptions()
+
+	serverAddr := currentFlag.String("addr", defaults.ServerAddr, "address and port for asr Wyoming server (\"auto\" or \"mdns:<name>\" to find one with mDNS)")
 	inputFilePath := currentFlag.String("input_file", "", "input WAV file path")
-	modelName := currentFlag.String("model-name", "", "name of model")
-	language := currentFlag.String("language", "", "language")
+	modelName := currentFlag.String("model-name", defaults.ModelName, "name of model")
+	language := currentFlag.String("language", defaults.Language, "language")
 
 	inputRawData := currentFlag.Bool("input-raw", false, "listen for audio data from stdin and output results to stdout in a loop")
 	inputRawDataRate := currentFlag.Int("input-raw-rate", 22050, "audio rate from stdin")
 	inputRawDataChannels := currentFlag.Int("input-raw-channels", 1, "number of audio channels from stdin")
+	splitChannels := currentFlag.Bool("split-channels", defaults.SplitChannels, "transcribe each audio channel separately and label the results by channel")
 
-	numWorkers := currentFlag.Int("num-workers", 3, "number of workers")
-	audioWindowMS := currentFlag.Int("audio-window-ms", 100, "window size in MS to use for detecting sound")
-	soundThreshold := currentFlag.Int("sound-threshold", 20000, "level of noise for a sound event")
-	silenceThreshold := currentFlag.Int("silence-threshold", 2000, "level of noise for a silence event")
-	minSoundDuration := currentFlag.Int("min-sound-duration-ms", 100, "minimum length of a sound event")
-	minSilenceDuration := currentFlag.Int("min-silence-duration-ms", 100, "minimum length of a silence event")
+	numWorkers := currentFlag.Int("num-workers", defaults.WorkersCount, "number of workers")
 
-	currentFlag.Parse(os.Args[2:])
+	var whole bool
+	currentFlag.BoolVar(&whole, "whole", defaults.Whole, "transcribe the whole recording without detecting sound")
+	currentFlag.BoolVar(&whole, "no-vad", defaults.Whole, "alias for -whole")
+	chunkMS := currentFlag.Int("chunk-ms", defaults.ChunkMS, "split recordings longer than this into chunks of this length when using -whole (0 to never split)")
+	chunkOverlapMS := currentFlag.Int("chunk-overlap-ms", defaults.ChunkOverlapMS, "length of audio shared by neighboring chunks when using -chunk-ms")
+
+	parseSegmenterFlags := addSegmenterFlagsASR(currentFlag)
+	parseTLSFlags := addTLSFlags(currentFlag)
+	parseConfigFlags := addConfigFlags(currentFlag, SERVICE_ASR, globals)
+
+	return func() (asrCommandArgs, error) {
+		if err := parseConfigFlags(); err != nil {
+			return asrCommandArgs{}, err
+		}
+		connectOptions, err := parseTLSFlags()
+		if err != nil {
+			return asrCommandArgs{}, err
+		}
 
-	if err := validateInputsASR(
-		*serverAddr,
-		*inputFilePath,
-		*inputRawData,
-		*inputRawDataRate,
-		*inputRawDataChannels,
-		*audioWindowMS,
-		int32(*soundThreshold),
-		int32(*silenceThreshold),
-		*minSoundDuration,
-		*minSilenceDuration,
-	); err != nil {
-		return "", "", "", "", false, 0, 0, 0, 0, 0, 0, 0, 0, err
+		options := wyoming.TranscribeOptions{
+			ServerAddr:     *serverAddr,
+			ConnectOptions: connectOptions,
+			ModelName:      *modelName,
+			Language:       *language,
+			WorkersCount:   *numWorkers,
+			Whole:          whole,
+			ChunkMS:        *chunkMS,
+			ChunkOverlapMS: *chunkOverlapMS,
+			SplitChannels:  *splitChannels,
+			Segmenter:      parseSegmenterFlags(),
+		}
+		options.Segmenter.ConnectOptions = connectOptions
+
+		if err := validateInputsASR(*inputFilePath, *inputRawData, *inputRawDataRate, *inputRawDataChannels, options); err != nil {
+			return asrCommandArgs{}, err
+		}
+
+		return asrCommandArgs{
+			InputFilePath:        *inputFilePath,
+			InputRawData:         *inputRawData,
+			InputRawDataRate:     *inputRawDataRate,
+			InputRawDataChannels: *inputRawDataChannels,
+			Options:              options,
+		}, nil
 	}
+}
 
-	return *serverAddr, *inputFilePath, *modelName, *language, *inputRawData, *inputRawDataRate, *inputRawDataChannels, *audioWindowMS, int32(*soundThreshold), int32(*silenceThreshold), *minSoundDuration, *minSilenceDuration, *numWorkers, nil
+func parseAndValidateFlagsASR(currentFlag *flag.FlagSet, globals GlobalOptions) (asrCommandArgs, error) {
+	validateFlags := addFlagsASR(currentFlag, globals)
+	currentFlag.Parse(os.Args[2:])
+	return validateFlags()
 }
 
-func ASR() error {
-	currentFlag := flag.NewFlagSet("asr", flag.ExitOnError)
+// flagSetASR returns the flag set of asr without parsing it.
+func flagSetASR([]string) *flag.FlagSet {
+	currentFlag := newFlagSet("asr")
+	addFlagsASR(currentFlag, GlobalOptions{})
+	return currentFlag
+}
+
+func ASR(globals GlobalOptions) error {
+	currentFlag := newFlagSet("asr")
+
+	args, err := parseAndValidateFlagsASR(currentFlag, globals)
+	if err != nil {
+		return err
+	}
+	options := args.Options
 
-	serverAddr, inputFilePath, modelName, language, inputRawData, inputRawDataRate, inputRawDataChannels, audioWindowMS, soundThreshold, silenceThreshold, minSoundDuration, minSilenceDuration, numWorkers, err := parseAndValidateFlagsASR(currentFlag)
+	options.ServerAddr, err = resolveServerAddr(options.ServerAddr, SERVICE_ASR)
 	if err != nil {
 		return err
 	}
 
-	if !inputRawData {
-		transcriptions, err := wyoming.TranscribeAllAudioGroupsFromFile(inputFilePath, modelName, language, serverAddr, audioWindowMS, minSoundDuration, minSilenceDuration, numWorkers, soundThreshold, silenceThreshold)
+	if !args.InputRawData {
+		transcriptions, err := wyoming.TranscribeFile(args.InputFilePath, options)
 		if err != nil {
 			return err
 		}
 
 		for i, transcription := range transcriptions {
-			_, err = fmt.Printf("%d: %f - %f '%s'\n", i, transcription.Start.Seconds(), transcription.End.Seconds(), transcription.Text)
+			if options.SplitChannels {
+				_, err = fmt.Printf("%d: [channel %d] %f - %f '%s'\n", i, transcription.Channel, transcription.Start.Seconds(), transcription.End.Seconds(), transcription.Text)
+			} else {
+				_, err = fmt.Printf("%d: %f - %f '%s'\n", i, transcription.Start.Seconds(), transcription.End.Seconds(), transcription.Text)
+			}
 			if err != nil {
 				return err
 			}
@@ -123,21 +200,51 @@ func ASR() error {
 		return nil
 	}
 
+	audioData := wyoming.WyomingAudioData{Rate: args.InputRawDataRate, Width: 2, Channels: args.InputRawDataChannels}
 	resultsChan := make(chan wyoming.Transcription)
 	errorsChan := make(chan error)
 
-	go wyoming.TranscribeAudioGroups(os.Stdin, wyoming.WyomingAudioData{Rate: inputRawDataRate, Width: 2, Channels: inputRawDataChannels}, serverAddr, modelName, language, numWorkers, audioWindowMS, minSoundDuration, minSilenceDuration, soundThreshold, silenceThreshold, resultsChan, errorsChan)
+	// cancelling stops the transcription if an error is returned before all of the results have been received
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go wyoming.TranscribeStreamContext(ctx, os.Stdin, audioData, options, resultsChan, errorsChan)
+
+	printTranscription := func(transcription wyoming.Transcription) {
+		if options.SplitChannels {
+			fmt.Printf("[channel %d] %s\n", transcription.Channel, transcription.Text)
+			return
+		}
+		fmt.Println(transcription.Text)
+	}
+
+	// transcriptions are printed as they arrive once the parts of split segments, or overlapping chunks with -whole,
+	// have been joined
+	var joiner interface {
+		Add(wyoming.Transcription) []wyoming.Transcription
+		Flush() []wyoming.Transcription
+	} = &wyoming.TranscriptionJoiner{}
+	if options.Whole {
+		joiner = &wyoming.OverlapMerger{Overlap: time.Duration(options.ChunkOverlapMS) * time.Millisecond}
+	}
 
 	for {
 		select {
 		case result, ok := <-resultsChan:
 			if !ok {
+				for _, transcription := range joiner.Flush() {
+					printTranscription(transcription)
+				}
 				return nil
 			}
 
-			fmt.Println(result.Text)
+			for _, transcription := range joiner.Add(result) {
+				printTranscription(transcription)
+			}
 		case err := <-errorsChan:
-			return err
+			// the end of the audio is reported as an error before the last results arrive
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return err
+			}
 		}
 	}
 }
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+// manifestEntry is one line of an asr-eval manifest. "audio_filepath" is accepted as an alias for "audio".
+type manifestEntry struct {
+	Audio         string `json:"audio"`
+	AudioFilePath string `json:"audio_filepath"`
+	Text          string `json:"text"`
+}
+
+// readManifestASREval reads the JSONL manifest at manifestPath. Relative audio paths are resolved from the
+// directory containing the manifest.
+func readManifestASREval(manifestPath string) ([]manifestEntry, error) {
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry manifestEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("manifest line %d: %w", lineNumber, err)
+		}
+		if entry.Audio == "" {
+			entry.Audio = entry.AudioFilePath
+		}
+		if entry.Audio == "" {
+			return nil, fmt.Errorf("manifest line %d: missing audio path", lineNumber)
+		}
+		if !filepath.IsAbs(entry.Audio) {
+			entry.Audio = filepath.Join(filepath.Dir(manifestPath), entry.Audio)
+		}
+
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("manifest has no entries")
+	}
+
+	return entries, nil
+}
+
+// addFlagsASREval adds the asr-eval flags. The returned function validates them after parsing.
+func addFlagsASREval(currentFlag *flag.FlagSet, globals GlobalOptions) func() (string, bool, wyoming.TranscribeOptions, error) {
+	defaults := wyoming.DefaultTranscribeOptions()
+
+	manifestPath := currentFlag.String("manifest", "", "JSONL file with an \"audio\" path and reference \"text\" on each line")
+	serverAddr := currentFlag.String("addr", defaults.ServerAddr, "address and port for asr Wyoming server")
+	modelName := currentFlag.String("model-name", defaults.ModelName, "name of model")
+	language := currentFlag.String("language", defaults.Language, "language")
+
+	numWorkers := currentFlag.Int("num-workers", defaults.WorkersCount, "number of workers")
+	quiet := currentFlag.Bool("quiet", false, "only print the error rates without alignments")
+
+	var whole bool
+	currentFlag.BoolVar(&whole, "whole", defaults.Whole, "transcribe the whole recording without detecting sound")
+	currentFlag.BoolVar(&whole, "no-vad", defaults.Whole, "alias for -whole")
+	chunkMS := currentFlag.Int("chunk-ms", defaults.ChunkMS, "split recordings longer than this into chunks of this length when using -whole (0 to never split)")
+	chunkOverlapMS := currentFlag.Int("chunk-overlap-ms", defaults.ChunkOverlapMS, "length of audio shared by neighboring chunks when using -chunk-ms")
+
+	parseSegmenterFlags := addSegmenterFlagsASR(currentFlag)
+	parseTLSFlags := addTLSFlags(currentFlag)
+	parseConfigFlags := addConfigFlags(currentFlag, SERVICE_ASR, globals)
+
+	return func() (string, bool, wyoming.TranscribeOptions, error) {
+		if err := parseConfigFlags(); err != nil {
+			return "", false, wyoming.TranscribeOptions{}, err
+		}
+		connectOptions, err := parseTLSFlags()
+		if err != nil {
+			return "", false, wyoming.TranscribeOptions{}, err
+		}
+
+		if *manifestPath == "" {
+			return "", false, wyoming.TranscribeOptions{}, errors.New("missing manifest file path")
+		}
+
+		options := wyoming.TranscribeOptions{
+			ServerAddr:     *serverAddr,
+			ConnectOptions: connectOptions,
+			ModelName:      *modelName,
+			Language:       *language,
+			WorkersCount:   *numWorkers,
+			Whole:          whole,
+			ChunkMS:        *chunkMS,
+			ChunkOverlapMS: *chunkOverlapMS,
+			Segmenter:      parseSegmenterFlags(),
+		}
+		options.Segmenter.ConnectOptions = connectOptions
+		if err := options.Validate(); err != nil {
+			return "", false, wyoming.TranscribeOptions{}, err
+		}
+
+		return *manifestPath, *quiet, options, nil
+	}
+}
+
+func parseAndValidateFlagsASREval(currentFlag *flag.FlagSet, globals GlobalOptions) (string, bool, wyoming.TranscribeOptions, error) {
+	validateFlags := addFlagsASREval(currentFlag, globals)
+	currentFlag.Parse(os.Args[2:])
+	return validateFlags()
+}
+
+// flagSetASREval returns the flag set of asr-eval without parsing it.
+func flagSetASREval([]string) *flag.FlagSet {
+	currentFlag := newFlagSet("asr-eval")
+	addFlagsASREval(currentFlag, GlobalOptions{})
+	return currentFlag
+}
+
+// ASREval transcribes every file listed in a manifest and reports the word and character error rates of the
+// transcriptions compared to the reference text.
+func ASREval(globals GlobalOptions) error {
+	currentFlag := newFlagSet("asr-eval")
+	manifestPath, quiet, options, err := parseAndValidateFlagsASREval(currentFlag, globals)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readManifestASREval(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	var totalWordErrors, totalCharacterErrors utils.ErrorCounts
+	failedCount := 0
+	for _, entry := range entries {
+		transcriptions, err := wyoming.TranscribeFile(entry.Audio, options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", entry.Audio, err)
+			failedCount += 1
+			continue
+		}
+
+		var texts []string
+		for _, transcription := range transcriptions {
+			texts = append(texts, transcription.Text)
+		}
+		hypothesis := strings.Join(texts, " ")
+
+		steps, wordErrors := utils.AlignWords(entry.Text, hypothesis)
+		characterErrors := utils.CharacterErrors(entry.Text, hypothesis)
+		totalWordErrors.Add(wordErrors)
+		totalCharacterErrors.Add(characterErrors)
+
+		fmt.Printf(
+			"%s: WER %.2f%% CER %.2f%% (S=%d D=%d I=%d N=%d)\n",
+			entry.Audio,
+			wordErrors.ErrorRate()*100,
+			characterErrors.ErrorRate()*100,
+			wordErrors.Substitutions,
+			wordErrors.Deletions,
+			wordErrors.Insertions,
+			wordErrors.Reference,
+		)
+		if !quiet {
+			refLine, hypLine, opLine := utils.FormatAlignment(steps)
+			fmt.Printf("REF: %s\nHYP: %s\n     %s\n\n", refLine, hypLine, opLine)
+		}
+	}
+
+	fmt.Printf(
+		"Total: WER %.2f%% CER %.2f%% (S=%d D=%d I=%d N=%d) files=%d failed=%d\n",
+		totalWordErrors.ErrorRate()*100,
+		totalCharacterErrors.ErrorRate()*100,
+		totalWordErrors.Substitutions,
+		totalWordErrors.Deletions,
+		totalWordErrors.Insertions,
+		totalWordErrors.Reference,
+		len(entries)-failedCount,
+		failedCount,
+	)
+
+	if failedCount == len(entries) {
+		return errors.New("all files failed to transcribe")
+	}
+
+	return nil
+}
@@ -0,0 +1,383 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+const BENCH_ASR = "asr"
+const BENCH_TTS = "tts"
+
+// benchResult is the outcome of one benchmark request. Durations are in seconds. Latency and FirstAudio are
+// measured from once the connection, including the describe request, has been made, which takes Connect.
+type benchResult struct {
+	Connect        float64
+	Latency        float64
+	FirstAudio     float64
+	AudioDuration  float64
+	Err            error
+	HasFirstAudio  bool
+	HasAudioLength bool
+}
+
+// LatencySummary describes a set of durations in milliseconds.
+type LatencySummary struct {
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Mean float64 `json:"mean_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// BenchReport summarizes a benchmark run.
+type BenchReport struct {
+	Mode              string          `json:"mode"`
+	Addr              string          `json:"addr"`
+	Requests          int             `json:"requests"`
+	Concurrency       int             `json:"concurrency"`
+	Errors            int             `json:"errors"`
+	ElapsedSeconds    float64         `json:"elapsed_seconds"`
+	RequestsPerSecond float64         `json:"requests_per_second"`
+	Connect           LatencySummary  `json:"connect"`
+	Latency           LatencySummary  `json:"latency"`
+	FirstAudio        *LatencySummary `json:"time_to_first_audio,omitempty"`
+	RealTimeFactor    float64         `json:"real_time_factor"`
+	FirstError        string          `json:"first_error,omitempty"`
+}
+
+// firstWriteWriter records the time of the first non-empty write before passing writes on to writer.
+type firstWriteWriter struct {
+	writer     io.Writer
+	firstWrite time.Time
+	written    int64
+}
+
+func (f *firstWriteWriter) Write(p []byte) (int, error) {
+	if f.firstWrite.IsZero() && len(p) > 0 {
+		f.firstWrite = time.Now()
+	}
+	f.written += int64(len(p))
+	return f.writer.Write(p)
+}
+
+func summarizeLatencies(seconds []float64) LatencySummary {
+	milliseconds := make([]float64, len(seconds))
+	maxMS := 0.0
+	for i, value := range seconds {
+		milliseconds[i] = value * 1000
+		maxMS = max(maxMS, milliseconds[i])
+	}
+
+	return LatencySummary{
+		P50:  utils.Percentile(milliseconds, 50),
+		P95:  utils.Percentile(milliseconds, 95),
+		P99:  utils.Percentile(milliseconds, 99),
+		Mean: utils.Mean(milliseconds),
+		Max:  maxMS,
+	}
+}
+
+// benchASRRequest transcribes PCMAudio once and returns the time taken to connect and to transcribe.
+func benchASRRequest(serverAddr string, connectOptions wyoming.ConnectOptions, modelName, language string, PCMAudio []byte, audioData wyoming.WyomingAudioData) benchResult {
+	result := benchResult{
+		AudioDuration:  float64(len(PCMAudio)) / float64(audioData.Rate*audioData.Width*audioData.Channels),
+		HasAudioLength: true,
+	}
+
+	connectStart := time.Now()
+	conn, err := wyoming.ConnectWithOptions(serverAddr, connectOptions)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer conn.Disconnect()
+	result.Connect = time.Since(connectStart).Seconds()
+
+	start := time.Now()
+	_, err = conn.TranscribeAudio(bytes.NewReader(PCMAudio), audioData, modelName, language)
+	result.Latency = time.Since(start).Seconds()
+	result.Err = err
+	return result
+}
+
+// benchTTSRequest synthesizes text once and returns the time taken to connect and to synthesize along with the
+// time until the first audio was received.
+func benchTTSRequest(serverAddr string, connectOptions wyoming.ConnectOptions, text string, voiceData wyoming.SynthesizeVoiceData) benchResult {
+	var result benchResult
+
+	connectStart := time.Now()
+	conn, err := wyoming.ConnectWithOptions(serverAddr, connectOptions)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer conn.Disconnect()
+	result.Connect = time.Since(connectStart).Seconds()
+
+	start := time.Now()
+	writer := firstWriteWriter{writer: io.Discard}
+	audioData, err := conn.SynthesizeAudio(text, voiceData, &writer)
+	result.Latency = time.Since(start).Seconds()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if !writer.firstWrite.IsZero() {
+		result.FirstAudio = writer.firstWrite.Sub(start).Seconds()
+		result.HasFirstAudio = true
+	}
+	if bytesPerSecond := audioData.Rate * audioData.Width * audioData.Channels; bytesPerSecond > 0 {
+		result.AudioDuration = float64(writer.written) / float64(bytesPerSecond)
+		result.HasAudioLength = true
+	}
+
+	return result
+}
+
+// runBench calls request requestsCount times using concurrency goroutines and summarizes the results.
+func runBench(mode, serverAddr string, requestsCount, concurrency int, request func() benchResult) BenchReport {
+	requestsChan := make(chan struct{})
+	resultsChan := make(chan benchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range requestsChan {
+				resultsChan <- request()
+			}
+		}()
+	}
+
+	start := time.Now()
+	go func() {
+		for i := 0; i < requestsCount; i += 1 {
+			requestsChan <- struct{}{}
+		}
+		close(requestsChan)
+		wg.Wait()
+		close(resultsChan)
+	}()
+
+	report := BenchReport{Mode: mode, Addr: serverAddr, Requests: requestsCount, Concurrency: concurrency}
+	var connects, latencies, firstAudio []float64
+	var processingTotal, audioTotal float64
+	for result := range resultsChan {
+		if result.Err != nil {
+			report.Errors += 1
+			if report.FirstError == "" {
+				report.FirstError = result.Err.Error()
+			}
+			continue
+		}
+
+		connects = append(connects, result.Connect)
+		latencies = append(latencies, result.Latency)
+		if result.HasFirstAudio {
+			firstAudio = append(firstAudio, result.FirstAudio)
+		}
+		if result.HasAudioLength {
+			processingTotal += result.Latency
+			audioTotal += result.AudioDuration
+		}
+	}
+	report.ElapsedSeconds = time.Since(start).Seconds()
+
+	report.Connect = summarizeLatencies(connects)
+	report.Latency = summarizeLatencies(latencies)
+	if len(firstAudio) > 0 {
+		summary := summarizeLatencies(firstAudio)
+		report.FirstAudio = &summary
+	}
+	if audioTotal > 0 {
+		report.RealTimeFactor = processingTotal / audioTotal
+	}
+	if report.ElapsedSeconds > 0 {
+		report.RequestsPerSecond = float64(len(latencies)) / report.ElapsedSeconds
+	}
+
+	return report
+}
+
+func printBenchReport(report BenchReport, outputJSON bool) error {
+	if outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Printf("mode: %s addr: %s\n", report.Mode, report.Addr)
+	fmt.Printf("requests: %d concurrency: %d errors: %d\n", report.Requests, report.Concurrency, report.Errors)
+	fmt.Printf("elapsed: %.3fs requests/second: %.2f\n", report.ElapsedSeconds, report.RequestsPerSecond)
+	fmt.Printf(
+		"connect: p50 %.1fms p95 %.1fms p99 %.1fms mean %.1fms max %.1fms\n",
+		report.Connect.P50, report.Connect.P95, report.Connect.P99, report.Connect.Mean, report.Connect.Max,
+	)
+	fmt.Printf(
+		"latency: p50 %.1fms p95 %.1fms p99 %.1fms mean %.1fms max %.1fms\n",
+		report.Latency.P50, report.Latency.P95, report.Latency.P99, report.Latency.Mean, report.Latency.Max,
+	)
+	if report.FirstAudio != nil {
+		fmt.Printf(
+			"time to first audio: p50 %.1fms p95 %.1fms p99 %.1fms mean %.1fms max %.1fms\n",
+			report.FirstAudio.P50, report.FirstAudio.P95, report.FirstAudio.P99, report.FirstAudio.Mean, report.FirstAudio.Max,
+		)
+	}
+	fmt.Printf("real-time factor: %.3f\n", report.RealTimeFactor)
+	if report.FirstError != "" {
+		fmt.Printf("first error: %s\n", report.FirstError)
+	}
+
+	return nil
+}
+
+// benchCommandArgs holds the values of the bench flags.
+type benchCommandArgs struct {
+	ServerAddr     string
+	ConnectOptions wyoming.ConnectOptions
+	InputFilePath  string
+	Text           string
+	VoiceName      string
+	ModelName      string
+	Language       string
+	RequestsCount  int
+	Concurrency    int
+	OutputJSON     bool
+}
+
+// addFlagsBench adds the flags of bench for mode. The returned function validates them after parsing.
+func addFlagsBench(currentFlag *flag.FlagSet, mode string, globals GlobalOptions) func() (benchCommandArgs, error) {
+	defaultAddr := "localhost:10300"
+	if mode == BENCH_TTS {
+		defaultAddr = "localhost:10200"
+	}
+
+	serverAddr := currentFlag.String("addr", defaultAddr, "address and port for the Wyoming server")
+	inputFilePath := currentFlag.String("input_file", "", "input WAV file path to transcribe (asr)")
+	text := currentFlag.String("text", "", "text to be spoken (tts)")
+	voiceName := currentFlag.String("voice-name", "", "voice name (tts)")
+	modelName := currentFlag.String("model-name", "", "name of model (asr)")
+	language := currentFlag.String("language", "", "language")
+
+	requestsCount := currentFlag.Int("requests", 10, "number of requests to send")
+	concurrency := currentFlag.Int("concurrency", 1, "number of requests to run at the same time")
+	outputJSON := currentFlag.Bool("json", false, "print the results as JSON")
+
+	parseTLSFlags := addTLSFlags(currentFlag)
+	parseConfigFlags := addConfigFlags(currentFlag, mode, globals)
+
+	return func() (benchCommandArgs, error) {
+		if err := parseConfigFlags(); err != nil {
+			return benchCommandArgs{}, err
+		}
+		connectOptions, err := parseTLSFlags()
+		if err != nil {
+			return benchCommandArgs{}, err
+		}
+
+		if *serverAddr == "" {
+			return benchCommandArgs{}, errors.New("missing server address")
+		}
+		if mode == BENCH_ASR && *inputFilePath == "" {
+			return benchCommandArgs{}, errors.New("missing input file path")
+		}
+		if mode == BENCH_TTS && *text == "" {
+			return benchCommandArgs{}, errors.New("missing text")
+		}
+		if *requestsCount <= 0 {
+			return benchCommandArgs{}, errors.New("requests must be greater than 0")
+		}
+		if *concurrency <= 0 {
+			return benchCommandArgs{}, errors.New("concurrency must be greater than 0")
+		}
+
+		return benchCommandArgs{
+			ServerAddr:     *serverAddr,
+			ConnectOptions: connectOptions,
+			InputFilePath:  *inputFilePath,
+			Text:           *text,
+			VoiceName:      *voiceName,
+			ModelName:      *modelName,
+			Language:       *language,
+			RequestsCount:  *requestsCount,
+			Concurrency:    *concurrency,
+			OutputJSON:     *outputJSON,
+		}, nil
+	}
+}
+
+func parseAndValidateFlagsBench(currentFlag *flag.FlagSet, mode string, globals GlobalOptions) (benchCommandArgs, error) {
+	validateFlags := addFlagsBench(currentFlag, mode, globals)
+	currentFlag.Parse(os.Args[3:])
+	return validateFlags()
+}
+
+// flagSetBench returns the flag set of bench for the mode in args without parsing it, or nil for an unknown mode.
+func flagSetBench(args []string) *flag.FlagSet {
+	if len(args) == 0 || (args[0] != BENCH_ASR && args[0] != BENCH_TTS) {
+		return nil
+	}
+
+	currentFlag := newFlagSet("bench " + args[0])
+	addFlagsBench(currentFlag, args[0], GlobalOptions{})
+	return currentFlag
+}
+
+// Bench sends repeated asr or tts requests to a Wyoming server and reports the latency and throughput.
+func Bench(globals GlobalOptions) error {
+	if len(os.Args) < 3 || (os.Args[2] != BENCH_ASR && os.Args[2] != BENCH_TTS) {
+		return errors.New("bench mode must be one of: asr, tts")
+	}
+	mode := os.Args[2]
+
+	currentFlag := newFlagSet("bench " + mode)
+	args, err := parseAndValidateFlagsBench(currentFlag, mode, globals)
+	if err != nil {
+		return err
+	}
+
+	var request func() benchResult
+	if mode == BENCH_ASR {
+		WAVFile, audioData, err := wyoming.OpenWAVFile(args.InputFilePath)
+		if err != nil {
+			return err
+		}
+		PCMAudio, err := io.ReadAll(WAVFile)
+		WAVFile.Close()
+		if err != nil {
+			return err
+		}
+
+		request = func() benchResult {
+			return benchASRRequest(args.ServerAddr, args.ConnectOptions, args.ModelName, args.Language, PCMAudio, audioData)
+		}
+	} else {
+		voiceData := wyoming.SynthesizeVoiceData{Name: args.VoiceName, Language: args.Language}
+		request = func() benchResult {
+			return benchTTSRequest(args.ServerAddr, args.ConnectOptions, args.Text, voiceData)
+		}
+	}
+
+	report := runBench(mode, args.ServerAddr, args.RequestsCount, args.Concurrency, request)
+	if err := printBenchReport(report, args.OutputJSON); err != nil {
+		return err
+	}
+
+	if report.Errors == report.Requests {
+		return errors.New("all requests failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+// PROGRAM_NAME is the name of the executable used in usage text and completion scripts.
+const PROGRAM_NAME = "wyoming-cli"
+
+// Version is the version printed by the version command. It can be set when building with
+// -ldflags "-X github.com/john-pettigrew/wyoming-cli/commands.Version=v1.2.3" and otherwise comes from the build
+// info.
+var Version = ""
+
+// GlobalOptions are the values of the global flags given before the command.
+type GlobalOptions struct {
+	// ConfigPath and Profile are used instead of WYOMING_CONFIG and WYOMING_PROFILE unless the command is given
+	// -config or -profile itself.
+	ConfigPath string
+	Profile    string
+}
+
+// Command is a subcommand of the CLI.
+type Command struct {
+	Name string
+	// Usage is the arguments after the command name.
+	Usage       string
+	Description string
+	Run         func(globals GlobalOptions) error
+	// Flags returns the flag set of the command without running it, given the arguments after the command name
+	// such as the mode of bench. It is nil for commands without flags.
+	Flags func(args []string) *flag.FlagSet
+	// FlagArgs are the arguments passed to Flags when none are given, such as the mode of bench.
+	FlagArgs []string
+	// Hidden commands are not listed in the usage text.
+	Hidden bool
+}
+
+// commandList is every subcommand in the order they are listed. It is filled in by init because some commands
+// use it.
+var commandList []Command
+
+func init() {
+	commandList = []Command{
+		{Name: "tts", Usage: "[flags]", Description: "Synthesize speech from text with a Wyoming tts server.", Run: TTS, Flags: flagSetTTS},
+		{Name: "asr", Usage: "[flags]", Description: "Transcribe a WAV file or raw audio with a Wyoming asr server.", Run: ASR, Flags: flagSetASR},
+		{Name: "asr-eval", Usage: "[flags]", Description: "Measure the word and character error rates of an asr server against a manifest of reference transcripts.", Run: ASREval, Flags: flagSetASREval},
+		{Name: "bench", Usage: "asr|tts [flags]", Description: "Send repeated asr or tts requests to a Wyoming server and report the latency and throughput.", Run: Bench, Flags: flagSetBench, FlagArgs: []string{BENCH_TTS}},
+		{Name: "serve-http", Usage: "[flags]", Description: "Run an HTTP gateway exposing the Wyoming servers as REST, OpenAI compatible and WebSocket endpoints.", Run: ServeHTTP, Flags: flagSetServeHTTP},
+		{Name: "discover", Usage: "[flags]", Description: "List the Wyoming services advertised with mDNS on the local network.", Run: Discover, Flags: flagSetDiscover},
+		{Name: "help", Usage: "[command]", Description: "Show the usage of the CLI or of a command.", Run: Help},
+		{Name: "version", Usage: "", Description: "Print the version and build information.", Run: PrintVersion},
+		{Name: "completion", Usage: "bash|zsh|fish", Description: "Print a shell completion script.", Run: Completion},
+		{Name: "__complete", Usage: "[words...]", Description: "Print completions for the words of a command line, used by the completion scripts.", Run: Complete, Hidden: true},
+	}
+}
+
+// findCommand returns the command called name.
+func findCommand(name string) (Command, bool) {
+	for _, command := range commandList {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return Command{}, false
+}
+
+// commandNames returns the names of the commands that are not hidden.
+func commandNames() []string {
+	var names []string
+	for _, command := range commandList {
+		if !command.Hidden {
+			names = append(names, command.Name)
+		}
+	}
+	return names
+}
+
+// newGlobalFlagSet returns the flag set for the flags given before the command.
+func newGlobalFlagSet() (*flag.FlagSet, *string, *string, *bool) {
+	globalFlag := flag.NewFlagSet(PROGRAM_NAME, flag.ContinueOnError)
+	configPath := globalFlag.String("config", "", "configuration file with default settings and named profiles (overrides WYOMING_CONFIG)")
+	profile := globalFlag.String("profile", "", "name of the configuration file profile to use (overrides WYOMING_PROFILE)")
+	showVersion := globalFlag.Bool("version", false, "print the version and exit")
+	globalFlag.Usage = func() { printUsage(globalFlag.Output(), globalFlag) }
+	return globalFlag, configPath, profile, showVersion
+}
+
+// printUsage prints the usage text of the CLI to output.
+func printUsage(output io.Writer, globalFlag *flag.FlagSet) {
+	fmt.Fprintf(output, "Usage: %s [global flags] <command> [flags]\n\nCommands:\n", PROGRAM_NAME)
+	for _, command := range commandList {
+		if !command.Hidden {
+			fmt.Fprintf(output, "  %-12s%s\n", command.Name, command.Description)
+		}
+	}
+	fmt.Fprintln(output, "\nGlobal flags:")
+	globalFlag.SetOutput(output)
+	globalFlag.PrintDefaults()
+	fmt.Fprintf(output, "\nRun \"%s help <command>\" for the flags of a command.\n", PROGRAM_NAME)
+}
+
+// newFlagSet returns the flag set for the command called name, which prints the command's usage text for -h.
+func newFlagSet(name string) *flag.FlagSet {
+	currentFlag := flag.NewFlagSet(name, flag.ExitOnError)
+	currentFlag.Usage = func() { printCommandUsage(currentFlag.Output(), name, currentFlag) }
+	return currentFlag
+}
+
+// printCommandUsage prints the usage text of the command whose flag set is called name.
+func printCommandUsage(output io.Writer, name string, currentFlag *flag.FlagSet) {
+	commandName, _, _ := strings.Cut(name, " ")
+	command, _ := findCommand(commandName)
+
+	fmt.Fprintf(output, "Usage: %s %s %s\n", PROGRAM_NAME, command.Name, command.Usage)
+	if command.Description != "" {
+		fmt.Fprintf(output, "\n%s\n", command.Description)
+	}
+
+	hasFlags := false
+	currentFlag.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(output, "\nFlags:")
+		currentFlag.SetOutput(output)
+		currentFlag.PrintDefaults()
+	}
+}
+
+// commandFlagSet returns the flag set of command for args, the arguments after the command name, without running
+// the command. The returned bool is false for commands without a flag set.
+func commandFlagSet(command Command, args []string) (*flag.FlagSet, bool) {
+	if command.Flags == nil {
+		return nil, false
+	}
+
+	currentFlag := command.Flags(args)
+	return currentFlag, currentFlag != nil
+}
+
+// Help prints the usage text of the CLI, or of the command named after "help", to stdout.
+func Help(GlobalOptions) error {
+	currentFlag := newFlagSet("help")
+	currentFlag.Parse(os.Args[2:])
+
+	if currentFlag.NArg() == 0 {
+		globalFlag, _, _, _ := newGlobalFlagSet()
+		printUsage(os.Stdout, globalFlag)
+		return nil
+	}
+
+	command, ok := findCommand(currentFlag.Arg(0))
+	if !ok {
+		return unknownCommandError(currentFlag.Arg(0))
+	}
+
+	args := command.FlagArgs
+	if currentFlag.NArg() > 1 {
+		args = currentFlag.Args()[1:]
+	}
+	commandFlag, ok := commandFlagSet(command, args)
+	if !ok {
+		commandFlag = flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	}
+	printCommandUsage(os.Stdout, commandFlag.Name(), commandFlag)
+	return nil
+}
+
+// buildVersion returns the version of the CLI.
+func buildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok && buildInfo.Main.Version != "" {
+		return buildInfo.Main.Version
+	}
+	return "(devel)"
+}
+
+// PrintVersion prints the version of the CLI with the Go version and the VCS information it was built from.
+func PrintVersion(GlobalOptions) error {
+	currentFlag := newFlagSet("version")
+	currentFlag.Parse(os.Args[2:])
+
+	line := PROGRAM_NAME + " " + buildVersion()
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		line += " " + buildInfo.GoVersion
+
+		settings := map[string]string{}
+		for _, setting := range buildInfo.Settings {
+			settings[setting.Key] = setting.Value
+		}
+		if revision := settings["vcs.revision"]; revision != "" {
+			line += " commit " + revision
+			if settings["vcs.modified"] == "true" {
+				line += "-dirty"
+			}
+		}
+		if buildTime := settings["vcs.time"]; buildTime != "" {
+			line += " built " + buildTime
+		}
+		if settings["GOOS"] != "" {
+			line += " " + settings["GOOS"] + "/" + settings["GOARCH"]
+		}
+	}
+
+	_, err := fmt.Println(line)
+	return err
+}
+
+// unknownCommandError returns an error for a command that does not exist, suggesting a close match.
+func unknownCommandError(name string) error {
+	suggestions := utils.SuggestNames(name, commandNames())
+	if len(suggestions) > 0 {
+		return fmt.Errorf("unknown command %q, did you mean %q?", name, suggestions[0])
+	}
+	return fmt.Errorf("unknown command %q, run \"%s help\" for a list of commands", name, PROGRAM_NAME)
+}
+
+// Run parses the global flags in os.Args and runs the command after them. os.Args is rewritten to start with the
+// command so commands can parse their own flags from os.Args[2:].
+func Run() error {
+	globalFlag, configPath, profile, showVersion := newGlobalFlagSet()
+	if err := globalFlag.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	globals := GlobalOptions{ConfigPath: *configPath, Profile: *profile}
+	if *showVersion {
+		return PrintVersion(globals)
+	}
+
+	if globalFlag.NArg() == 0 {
+		printUsage(os.Stderr, globalFlag)
+		return errors.New("missing command")
+	}
+
+	os.Args = append([]string{os.Args[0]}, globalFlag.Args()...)
+	command, ok := findCommand(os.Args[1])
+	if !ok {
+		return unknownCommandError(os.Args[1])
+	}
+
+	return command.Run(globals)
+}
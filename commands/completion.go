@@ -0,0 +1,278 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+// The completion scripts pass the words of the command line after the program name, ending with the word being
+// completed, to the hidden __complete command and offer the lines it prints.
+
+const BASH_COMPLETION = `# bash completion for wyoming-cli, load with: source <(wyoming-cli completion bash)
+_wyoming_cli() {
+    local IFS=$'\n'
+    COMPREPLY=($(wyoming-cli __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _wyoming_cli wyoming-cli
+`
+
+const ZSH_COMPLETION = `#compdef wyoming-cli
+# zsh completion for wyoming-cli, load with: source <(wyoming-cli completion zsh)
+_wyoming_cli() {
+    local -a candidates
+    candidates=("${(@f)$(wyoming-cli __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if (( ${#candidates} )) && [[ -n "${candidates[1]}" ]]; then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+if [[ "$funcstack[1]" == "_wyoming_cli" ]]; then
+    _wyoming_cli "$@"
+else
+    compdef _wyoming_cli wyoming-cli
+fi
+`
+
+const FISH_COMPLETION = `# fish completion for wyoming-cli, load with: wyoming-cli completion fish | source
+function __wyoming_cli_complete
+    set -l words (commandline -opc)
+    wyoming-cli __complete $words[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c wyoming-cli -a '(__wyoming_cli_complete)'
+`
+
+var completionScripts = map[string]string{
+	"bash": BASH_COMPLETION,
+	"zsh":  ZSH_COMPLETION,
+	"fish": FISH_COMPLETION,
+}
+
+// Completion prints the completion script for the shell named after "completion".
+func Completion(GlobalOptions) error {
+	currentFlag := newFlagSet("completion")
+	currentFlag.Parse(os.Args[2:])
+
+	if currentFlag.NArg() != 1 {
+		return errors.New("completion requires one shell: bash, zsh or fish")
+	}
+	script, ok := completionScripts[currentFlag.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q, expected one of: bash, zsh, fish", currentFlag.Arg(0))
+	}
+
+	_, err := fmt.Print(script)
+	return err
+}
+
+// filterPrefix returns the candidates starting with prefix.
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// flagNames returns the flags of currentFlag as "-name".
+func flagNames(currentFlag *flag.FlagSet) []string {
+	var names []string
+	currentFlag.VisitAll(func(f *flag.Flag) { names = append(names, "-"+f.Name) })
+	return names
+}
+
+// flagTakesValue returns true if the flag called name in currentFlag needs a value after it.
+func flagTakesValue(currentFlag *flag.FlagSet, name string) bool {
+	f := currentFlag.Lookup(name)
+	if f == nil {
+		return false
+	}
+	boolFlag, isBool := f.Value.(interface{ IsBoolFlag() bool })
+	return !isBool || !boolFlag.IsBoolFlag()
+}
+
+// trimFlagDashes returns word without its leading dashes and the value after "=", and whether word is a flag.
+func trimFlagDashes(word string) (string, bool) {
+	if !strings.HasPrefix(word, "-") || word == "-" || word == "--" {
+		return "", false
+	}
+	name := strings.TrimLeft(word, "-")
+	name, _, _ = strings.Cut(name, "=")
+	return name, true
+}
+
+// splitGlobalFlags returns the index of the command in words, skipping the global flags before it, or -1 if there
+// is no command yet.
+func splitGlobalFlags(globalFlag *flag.FlagSet, words []string) int {
+	for i := 0; i < len(words); i += 1 {
+		name, isFlag := trimFlagDashes(words[i])
+		if !isFlag {
+			return i
+		}
+		if !strings.Contains(words[i], "=") && flagTakesValue(globalFlag, name) {
+			i += 1
+		}
+	}
+	return -1
+}
+
+// profileNames returns the profiles in the configuration file.
+func profileNames() []string {
+	configFile, err := os.Open(defaultConfigPath(GlobalOptions{}))
+	if err != nil {
+		return nil
+	}
+	defer configFile.Close()
+
+	config, err := utils.ReadConfig(configFile)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// voiceNames returns the voices of the tts server given by the flags already parsed into currentFlag.
+func voiceNames(currentFlag *flag.FlagSet) []string {
+	serverAddr := currentFlag.Lookup("addr").Value.String()
+	addrSet := false
+	currentFlag.Visit(func(f *flag.Flag) { addrSet = addrSet || f.Name == "addr" })
+	if !addrSet {
+		if envAddr := os.Getenv(envNameForFlag(SERVICE_TTS + "-addr")); envAddr != "" {
+			serverAddr = envAddr
+		}
+	}
+
+	serverAddr, err := resolveServerAddr(serverAddr, SERVICE_TTS)
+	if err != nil {
+		return nil
+	}
+	wyomingConn, err := wyoming.Connect(serverAddr)
+	if err != nil {
+		return nil
+	}
+	defer wyomingConn.Disconnect()
+
+	var names []string
+	for _, voice := range wyomingConn.ListVoices() {
+		names = append(names, voice.Name)
+	}
+	return names
+}
+
+// flagValueCompletions returns the values to offer for the flag called name of the command whose flags are in
+// currentFlag.
+func flagValueCompletions(currentFlag *flag.FlagSet, name string) []string {
+	switch name {
+	case "voice-name":
+		if currentFlag.Lookup("addr") != nil {
+			return voiceNames(currentFlag)
+		}
+	case "vad":
+		return []string{utils.VAD_PEAK, utils.VAD_ENERGY, utils.VAD_SPECTRAL}
+	case "profile":
+		return profileNames()
+	case "addr":
+		return []string{"auto"}
+	}
+	return nil
+}
+
+// completeWords returns the completions for the last word in words, which are the words of a command line after
+// the program name.
+func completeWords(words []string) []string {
+	current := words[len(words)-1]
+	previous := words[:len(words)-1]
+
+	globalFlag, _, _, _ := newGlobalFlagSet()
+	commandIndex := splitGlobalFlags(globalFlag, previous)
+	if commandIndex == -1 {
+		if len(previous) > 0 {
+			if name, isFlag := trimFlagDashes(previous[len(previous)-1]); isFlag && flagTakesValue(globalFlag, name) {
+				return filterPrefix(flagValueCompletions(globalFlag, name), current)
+			}
+		}
+		if strings.HasPrefix(current, "-") {
+			return filterPrefix(flagNames(globalFlag), current)
+		}
+		return filterPrefix(commandNames(), current)
+	}
+
+	command, ok := findCommand(previous[commandIndex])
+	if !ok {
+		return nil
+	}
+	args := previous[commandIndex+1:]
+
+	switch command.Name {
+	case "help":
+		if len(args) == 0 {
+			return filterPrefix(commandNames(), current)
+		}
+		return nil
+	case "completion":
+		if len(args) == 0 {
+			return filterPrefix([]string{"bash", "fish", "zsh"}, current)
+		}
+		return nil
+	case "bench":
+		if len(args) == 0 {
+			return filterPrefix([]string{BENCH_ASR, BENCH_TTS}, current)
+		}
+	}
+
+	flagArgs := command.FlagArgs
+	if command.Name == "bench" {
+		flagArgs = args[:1]
+		args = args[1:]
+	}
+	currentFlag, ok := commandFlagSet(command, flagArgs)
+	if !ok {
+		return nil
+	}
+
+	if len(args) > 0 {
+		if name, isFlag := trimFlagDashes(args[len(args)-1]); isFlag && !strings.Contains(args[len(args)-1], "=") && flagTakesValue(currentFlag, name) {
+			// parse the earlier flags so completions can use them, such as -addr for -voice-name
+			currentFlag.Init(currentFlag.Name(), flag.ContinueOnError)
+			currentFlag.SetOutput(io.Discard)
+			currentFlag.Parse(args[:len(args)-1])
+			return filterPrefix(flagValueCompletions(currentFlag, name), current)
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		return filterPrefix(flagNames(currentFlag), current)
+	}
+	return nil
+}
+
+// Complete prints the completions for the words after "__complete", one per line.
+func Complete(GlobalOptions) error {
+	words := os.Args[2:]
+	if len(words) == 0 {
+		words = []string{""}
+	}
+
+	for _, completion := range completeWords(words) {
+		if _, err := fmt.Println(completion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+// ENV_PREFIX starts the environment variables that set flags, such as WYOMING_TTS_ADDR for -addr of tts.
+const ENV_PREFIX = "WYOMING_"
+
+// envFlags are the settings that can be set with WYOMING_<SETTING> environment variables, such as WYOMING_TLS_CA for
+// -tls-ca. "asr-addr" and "tts-addr" also set -addr of commands talking to a single server of that type. Settings
+// with content to read or write, such as -text or -output_file, are left out so a variable can't silently change
+// what a command reads or writes.
+var envFlags = []string{"asr-addr", "tts-addr", "vad-addr", "tls-ca", "tls-cert", "tls-key", "tls-server-name", "tls-insecure-skip-verify"}
+
+// serviceEnvFlags are the settings of commands talking to a single asr or tts server that can be set with
+// WYOMING_<SERVICE>_<SETTING> environment variables, such as WYOMING_TTS_VOICE_NAME for -voice-name of tts, so that
+// a variable for one type of server does not apply to the other.
+var serviceEnvFlags = []string{"model-name", "voice-name", "speaker", "language"}
+
+// defaultConfigPath returns the path of the configuration file used when -config is not given.
+func defaultConfigPath(globals GlobalOptions) string {
+	if globals.ConfigPath != "" {
+		return globals.ConfigPath
+	}
+	if configPath := os.Getenv(ENV_PREFIX + "CONFIG"); configPath != "" {
+		return configPath
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "wyoming-cli", "config.toml")
+}
+
+// envNameForFlag returns the environment variable for the flag called name.
+func envNameForFlag(name string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// configFlagName returns the flag set by the setting called key, or "" if currentFlag has no such flag.
+// "asr-addr" and "tts-addr" set -addr for commands talking to a single serviceType server.
+func configFlagName(currentFlag *flag.FlagSet, serviceType, key string) string {
+	if currentFlag.Lookup(key) != nil {
+		return key
+	}
+	if serviceType != "" && key == serviceType+"-addr" && currentFlag.Lookup("addr") != nil {
+		return "addr"
+	}
+	return ""
+}
+
+// applyConfigSettings sets the flags named by settings that are not in skip. Settings for flags the command does not
+// have are ignored so profiles can be shared between commands.
+func applyConfigSettings(currentFlag *flag.FlagSet, serviceType string, settings map[string]string, skip map[string]bool, source string) error {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	// "addr" is applied before "asr-addr" and "tts-addr" so the more specific setting wins
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := configFlagName(currentFlag, serviceType, key)
+		if name == "" || skip[name] {
+			continue
+		}
+		if err := currentFlag.Set(name, settings[key]); err != nil {
+			return fmt.Errorf("%s: %s: %w", source, key, err)
+		}
+	}
+
+	return nil
+}
+
+// addConfigFlags adds the -config and -profile flags, which default to the global flags in globals. The returned
+// function must be called after parsing and sets the flags that were not given on the command line from, in
+// increasing priority, the configuration file defaults, the profile and the environment variables for envFlags and
+// serviceEnvFlags. serviceType is SERVICE_ASR or SERVICE_TTS for commands talking to a single server and ""
+// otherwise.
+func addConfigFlags(currentFlag *flag.FlagSet, serviceType string, globals GlobalOptions) func() error {
+	configPath := currentFlag.String("config", defaultConfigPath(globals), "configuration file with default settings and named profiles")
+	defaultProfile := globals.Profile
+	if defaultProfile == "" {
+		defaultProfile = os.Getenv(ENV_PREFIX + "PROFILE")
+	}
+	profile := currentFlag.String("profile", defaultProfile, "name of the configuration file profile to use")
+
+	return func() error {
+		explicitFlags := map[string]bool{}
+		currentFlag.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+		// a configuration file given with the global flag must exist too
+		explicitFlags["config"] = explicitFlags["config"] || globals.ConfigPath != ""
+
+		var config utils.Config
+		configFile, err := os.Open(*configPath)
+		if err != nil {
+			// the default configuration file is optional
+			if !errors.Is(err, os.ErrNotExist) || explicitFlags["config"] || *profile != "" {
+				return err
+			}
+		} else {
+			config, err = utils.ReadConfig(configFile)
+			configFile.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", *configPath, err)
+			}
+		}
+
+		if err := applyConfigSettings(currentFlag, serviceType, config.Defaults, explicitFlags, *configPath); err != nil {
+			return err
+		}
+
+		if *profile != "" {
+			settings, ok := config.Profiles[*profile]
+			if !ok {
+				return fmt.Errorf("unknown profile %q in %s", *profile, *configPath)
+			}
+			if err := applyConfigSettings(currentFlag, serviceType, settings, explicitFlags, "profile "+*profile); err != nil {
+				return err
+			}
+		}
+
+		envSettings := map[string]string{}
+		for _, name := range envFlags {
+			if value, ok := os.LookupEnv(envNameForFlag(name)); ok {
+				envSettings[name] = value
+			}
+		}
+		if serviceType != "" {
+			for _, name := range serviceEnvFlags {
+				if value, ok := os.LookupEnv(envNameForFlag(serviceType + "-" + name)); ok {
+					envSettings[name] = value
+				}
+			}
+		}
+
+		return applyConfigSettings(currentFlag, serviceType, envSettings, explicitFlags, "environment")
+	}
+}
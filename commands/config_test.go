@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddConfigFlags(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	config := `tts-addr = "defaults:10200"
+voice-name = "en_US-amy"
+
+[profiles.remote]
+tts-addr = "remote:10200"
+tls-ca = "/etc/ca.pem"
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		globals GlobalOptions
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			globals: GlobalOptions{ConfigPath: configPath},
+			want:    map[string]string{"addr": "defaults:10200", "voice-name": "en_US-amy", "tls-ca": ""},
+		},
+		{
+			name:    "profile",
+			args:    []string{"-profile", "remote"},
+			globals: GlobalOptions{ConfigPath: configPath},
+			want:    map[string]string{"addr": "remote:10200", "voice-name": "en_US-amy", "tls-ca": "/etc/ca.pem"},
+		},
+		{
+			name:    "global profile",
+			globals: GlobalOptions{ConfigPath: configPath, Profile: "remote"},
+			want:    map[string]string{"addr": "remote:10200"},
+		},
+		{
+			name:    "environment",
+			env:     map[string]string{"WYOMING_TTS_ADDR": "env:10200", "WYOMING_TTS_VOICE_NAME": "de_DE-thorsten", "WYOMING_TLS_CA": "/env/ca.pem"},
+			globals: GlobalOptions{ConfigPath: configPath, Profile: "remote"},
+			want:    map[string]string{"addr": "env:10200", "voice-name": "de_DE-thorsten", "tls-ca": "/env/ca.pem"},
+		},
+		{
+			// variables for other services, unscoped service settings and content flags are ignored
+			name: "ignored environment",
+			env: map[string]string{
+				"WYOMING_ASR_ADDR":       "asr:10300",
+				"WYOMING_ADDR":           "any:10200",
+				"WYOMING_VOICE_NAME":     "de_DE-thorsten",
+				"WYOMING_ASR_VOICE_NAME": "de_DE-thorsten",
+				"WYOMING_TEXT":           "hello",
+			},
+			globals: GlobalOptions{ConfigPath: configPath},
+			want:    map[string]string{"addr": "defaults:10200", "voice-name": "en_US-amy", "text": ""},
+		},
+		{
+			name:    "command line",
+			args:    []string{"-addr", "flag:10200", "-voice-name", "flag-voice"},
+			env:     map[string]string{"WYOMING_TTS_ADDR": "env:10200", "WYOMING_TTS_VOICE_NAME": "de_DE-thorsten"},
+			globals: GlobalOptions{ConfigPath: configPath, Profile: "remote"},
+			want:    map[string]string{"addr": "flag:10200", "voice-name": "flag-voice"},
+		},
+		{
+			name:    "command config",
+			args:    []string{"-config", configPath},
+			globals: GlobalOptions{ConfigPath: filepath.Join(t.TempDir(), "missing.toml")},
+			want:    map[string]string{"addr": "defaults:10200"},
+		},
+		{
+			name: "missing default config",
+			env:  map[string]string{"WYOMING_CONFIG": filepath.Join(t.TempDir(), "missing.toml")},
+			want: map[string]string{"addr": "localhost:10200"},
+		},
+		{
+			name:    "missing global config",
+			globals: GlobalOptions{ConfigPath: filepath.Join(t.TempDir(), "missing.toml")},
+			wantErr: true,
+		},
+		{
+			name:    "unknown profile",
+			args:    []string{"-profile", "missing"},
+			globals: GlobalOptions{ConfigPath: configPath},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			currentFlag := flag.NewFlagSet("tts", flag.ContinueOnError)
+			currentFlag.SetOutput(io.Discard)
+			currentFlag.String("addr", "localhost:10200", "")
+			currentFlag.String("voice-name", "", "")
+			currentFlag.String("text", "", "")
+			currentFlag.String("tls-ca", "", "")
+			applyConfig := addConfigFlags(currentFlag, SERVICE_TTS, test.globals)
+			if err := currentFlag.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyConfig()
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("applying the configuration returned no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applying the configuration returned error %v", err)
+			}
+
+			for name, want := range test.want {
+				if got := currentFlag.Lookup(name).Value.String(); got != want {
+					t.Errorf("-%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/john-pettigrew/wyoming-cli/mdns"
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+const SERVICE_ASR = "asr"
+const SERVICE_TTS = "tts"
+
+// MDNS_TIMEOUT_MS is how long to wait for mDNS answers when resolving "auto" and "mdns:<name>" addresses.
+const MDNS_TIMEOUT_MS = 2000
+
+// MDNS_ADDR_PREFIX starts server addresses naming an mDNS service instance, such as "mdns:piper".
+const MDNS_ADDR_PREFIX = "mdns:"
+
+// discoveredService is an advertised Wyoming service with the services reported by its "describe" message.
+type discoveredService struct {
+	Service       mdns.Service
+	VoiceServices wyoming.WyomingVoiceServicesData
+	Err           error
+}
+
+// hasServiceType returns true if the server reports a service of serviceType, SERVICE_ASR or SERVICE_TTS.
+func (d discoveredService) hasServiceType(serviceType string) bool {
+	switch serviceType {
+	case SERVICE_ASR:
+		return len(d.VoiceServices.ASR) > 0
+	case SERVICE_TTS:
+		return len(d.VoiceServices.TTS) > 0
+	}
+	return false
+}
+
+// describeService connects to the Wyoming server at the TCP address serverAddr and returns the services it
+// reports. Connecting and the "describe" exchange must finish within timeout.
+func describeService(serverAddr string, timeout time.Duration) (wyoming.WyomingVoiceServicesData, error) {
+	deadline := time.Now().Add(timeout)
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.Dial("tcp", serverAddr)
+	if err != nil {
+		return wyoming.WyomingVoiceServicesData{}, err
+	}
+	wyomingConn := wyoming.WyomingConnection{ServerAddr: serverAddr, Conn: conn}
+	defer wyomingConn.Disconnect()
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return wyoming.WyomingVoiceServicesData{}, err
+	}
+
+	return wyomingConn.GetAvailableServices()
+}
+
+// discoverServices browses for Wyoming services and describes each one that answers. The services are described
+// at the same time and each one has timeout to answer.
+func discoverServices(timeout time.Duration) ([]discoveredService, error) {
+	services, err := mdns.Browse(mdns.WYOMING_SERVICE, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	discovered := make([]discoveredService, len(services))
+	wg := sync.WaitGroup{}
+	for i, service := range services {
+		discovered[i].Service = service
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			discovered[i].VoiceServices, discovered[i].Err = describeService(service.Addr(), timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	return discovered, nil
+}
+
+// resolveServerAddr returns serverAddr unchanged unless it is "auto", which resolves to the first advertised
+// Wyoming server offering serviceType, or "mdns:<name>", which resolves to the advertised instance called name.
+func resolveServerAddr(serverAddr, serviceType string) (string, error) {
+	instance, isMDNS := strings.CutPrefix(serverAddr, MDNS_ADDR_PREFIX)
+	if serverAddr != "auto" && !isMDNS {
+		return serverAddr, nil
+	}
+
+	if isMDNS {
+		services, err := mdns.Browse(mdns.WYOMING_SERVICE, MDNS_TIMEOUT_MS*time.Millisecond)
+		if err != nil {
+			return "", err
+		}
+
+		var names []string
+		for _, service := range services {
+			if strings.EqualFold(service.Instance, instance) {
+				return service.Addr(), nil
+			}
+			names = append(names, service.Instance)
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("no Wyoming services found for %q", serverAddr)
+		}
+		return "", fmt.Errorf("no Wyoming service named %q, found: %s", instance, strings.Join(names, ", "))
+	}
+
+	discovered, err := discoverServices(MDNS_TIMEOUT_MS * time.Millisecond)
+	if err != nil {
+		return "", err
+	}
+	for _, service := range discovered {
+		if service.Err == nil && service.hasServiceType(serviceType) {
+			return service.Service.Addr(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no Wyoming %s service found", serviceType)
+}
+
+// printDiscoveredService prints the address of service followed by the services it reported.
+func printDiscoveredService(service discoveredService) error {
+	if _, err := fmt.Printf("%s\t%s\n", service.Service.Instance, service.Service.Addr()); err != nil {
+		return err
+	}
+	if service.Err != nil {
+		_, err := fmt.Printf("  error: %s\n", service.Err)
+		return err
+	}
+
+	for _, ttsService := range service.VoiceServices.TTS {
+		var voices []string
+		for _, voice := range ttsService.Voices {
+			voices = append(voices, voice.Name)
+		}
+		if _, err := fmt.Printf("  tts: %s\tvoices: %s\n", ttsService.Name, strings.Join(voices, ", ")); err != nil {
+			return err
+		}
+	}
+	for _, asrService := range service.VoiceServices.ASR {
+		if _, err := fmt.Printf("  asr: %s\tlanguages: %s\n", asrService.Name, strings.Join(asrService.Languages, ", ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addFlagsDiscover adds the discover flags. The returned function validates them after parsing.
+func addFlagsDiscover(currentFlag *flag.FlagSet) func() (int, error) {
+	timeoutMS := currentFlag.Int("timeout-ms", MDNS_TIMEOUT_MS, "how long to wait for services to answer")
+
+	return func() (int, error) {
+		if *timeoutMS <= 0 {
+			return 0, errors.New("timeout-ms must be greater than 0")
+		}
+
+		return *timeoutMS, nil
+	}
+}
+
+func parseAndValidateFlagsDiscover(currentFlag *flag.FlagSet) (int, error) {
+	validateFlags := addFlagsDiscover(currentFlag)
+	currentFlag.Parse(os.Args[2:])
+	return validateFlags()
+}
+
+// flagSetDiscover returns the flag set of discover without parsing it.
+func flagSetDiscover([]string) *flag.FlagSet {
+	currentFlag := newFlagSet("discover")
+	addFlagsDiscover(currentFlag)
+	return currentFlag
+}
+
+// Discover lists the Wyoming services advertised with mDNS on the local network.
+func Discover(GlobalOptions) error {
+	currentFlag := newFlagSet("discover")
+	timeoutMS, err := parseAndValidateFlagsDiscover(currentFlag)
+	if err != nil {
+		return err
+	}
+
+	discovered, err := discoverServices(time.Duration(timeoutMS) * time.Millisecond)
+	if err != nil {
+		return err
+	}
+	if len(discovered) == 0 {
+		return errors.New("no Wyoming services found")
+	}
+
+	for _, service := range discovered {
+		if err := printDiscoveredService(service); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,396 @@
+package commands
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+const AUDIO_FORMAT_WAV = "wav"
+const AUDIO_FORMAT_RAW = "raw"
+
+// httpGateway serves the HTTP endpoints by proxying requests to Wyoming servers.
+type httpGateway struct {
+	TTSAddr string
+	// TTSConnectOptions are used to connect to TTSAddr.
+	TTSConnectOptions wyoming.ConnectOptions
+	// Transcribe holds the ASR server address and the options used for every transcription. The model, language
+	// and whole setting are set by each request.
+	Transcribe   wyoming.TranscribeOptions
+	MaxBodyBytes int64
+	TTSCache     *wyoming.TTSCache
+	// WSOrigins are the browser origins allowed to open WebSocket streams.
+	WSOrigins []string
+}
+
+// ttsRequestHTTP is the JSON body of a POST /v1/tts request.
+type ttsRequestHTTP struct {
+	Text     string `json:"text"`
+	Voice    string `json:"voice"`
+	Speaker  string `json:"speaker"`
+	Language string `json:"language"`
+	// Format is "wav" or "raw".
+	Format string `json:"format"`
+}
+
+// transcriptionHTTP is a Transcription with times in seconds.
+type transcriptionHTTP struct {
+	Text  string  `json:"text"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// httpAudioWriter streams audio to an HTTP response, flushing after every write. The response headers, and the
+// WAV header for "wav", are written once the first audio has been received with a valid format, so that errors
+// before then can still be sent as a JSON response.
+type httpAudioWriter struct {
+	ResponseWriter http.ResponseWriter
+	Format         string
+
+	started bool
+}
+
+func (h *httpAudioWriter) WriteAudioFormat(audioData wyoming.WyomingAudioData) error {
+	if h.started {
+		return nil
+	}
+	if audioData.Rate <= 0 || audioData.Width <= 0 || audioData.Channels <= 0 {
+		return errors.New("invalid audio format from the Wyoming server")
+	}
+	h.started = true
+
+	header := h.ResponseWriter.Header()
+	header.Set("X-Audio-Rate", strconv.Itoa(audioData.Rate))
+	header.Set("X-Audio-Width", strconv.Itoa(audioData.Width))
+	header.Set("X-Audio-Channels", strconv.Itoa(audioData.Channels))
+
+	if h.Format == AUDIO_FORMAT_RAW {
+		header.Set("Content-Type", fmt.Sprintf("audio/L%d; rate=%d; channels=%d", audioData.Width*8, audioData.Rate, audioData.Channels))
+		h.ResponseWriter.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	header.Set("Content-Type", "audio/wav")
+	h.ResponseWriter.WriteHeader(http.StatusOK)
+	return utils.WriteWAVHeader(h.ResponseWriter, utils.STREAMING_WAV_DATA_LENGTH, int32(audioData.Rate), int16(audioData.Channels), int16(audioData.Width*8))
+}
+
+func (h *httpAudioWriter) Write(p []byte) (int, error) {
+	n, err := h.ResponseWriter.Write(p)
+	if flusher, ok := h.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// writeJSONHTTP writes value as a JSON response with status.
+func writeJSONHTTP(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// requestBodyStatus returns the status for an error reading a request body, which is 413 when the body is larger
+// than the limit set with http.MaxBytesReader.
+func requestBodyStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// writeErrorHTTP writes err as a JSON error response with status and logs errors caused by the Wyoming server.
+func writeErrorHTTP(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	writeJSONHTTP(w, status, map[string]string{"error": err.Error()})
+}
+
+// synthesizeHTTP synthesizes text to w as it is received from the Wyoming server. Voice names in defaultVoices
+// that the server does not report, including when it reports no voices, use the server's default voice. Errors are
+// written with writeError if no audio has been sent yet.
+func (g *httpGateway) synthesizeHTTP(w http.ResponseWriter, text string, voiceData wyoming.SynthesizeVoiceData, defaultVoices map[string]bool, format string, writeError func(http.ResponseWriter, int, error)) {
+	wyomingConn, err := wyoming.ConnectWithOptions(g.TTSAddr, g.TTSConnectOptions)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	defer wyomingConn.Disconnect()
+	wyomingConn.TTSCache = g.TTSCache
+
+	if defaultVoices[voiceData.Name] {
+		reported := false
+		for _, voice := range wyomingConn.ListVoices() {
+			reported = reported || voice.Name == voiceData.Name
+		}
+		if !reported {
+			voiceData.Name = ""
+		}
+	}
+	if err := wyomingConn.ValidateVoice(voiceData); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	audioWriter := httpAudioWriter{ResponseWriter: w, Format: format}
+	audioData, err := wyomingConn.SynthesizeAudio(text, voiceData, &audioWriter)
+	if err != nil {
+		if !audioWriter.started {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		// The status has already been sent, so the error can't be reported. Panicking with http.ErrAbortHandler
+		// makes net/http close the connection without logging a stack trace, which lets the client see that the
+		// audio is incomplete instead of receiving a response that looks complete.
+		fmt.Fprintln(os.Stderr, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	if !audioWriter.started {
+		// the server sent no audio, send an empty response if the audio format is known
+		if err := audioWriter.WriteAudioFormat(audioData); err != nil {
+			writeError(w, http.StatusBadGateway, errors.New("no audio received from the Wyoming server"))
+		}
+	}
+}
+
+// handleTTS handles POST /v1/tts.
+func (g *httpGateway) handleTTS(w http.ResponseWriter, r *http.Request) {
+	var request ttsRequestHTTP
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, g.MaxBodyBytes)).Decode(&request); err != nil {
+		writeErrorHTTP(w, requestBodyStatus(err), err)
+		return
+	}
+	if request.Text == "" {
+		writeErrorHTTP(w, http.StatusBadRequest, errors.New("missing text"))
+		return
+	}
+	if request.Format == "" {
+		request.Format = AUDIO_FORMAT_WAV
+	}
+	if request.Format != AUDIO_FORMAT_WAV && request.Format != AUDIO_FORMAT_RAW {
+		writeErrorHTTP(w, http.StatusBadRequest, errors.New("format must be one of: wav, raw"))
+		return
+	}
+
+	voiceData := wyoming.SynthesizeVoiceData{Name: request.Voice, Speaker: request.Speaker, Language: request.Language}
+	g.synthesizeHTTP(w, request.Text, voiceData, nil, request.Format, writeErrorHTTP)
+}
+
+// saveUploadHTTP copies reader to a temporary file. The caller is responsible for removing the file.
+func saveUploadHTTP(reader io.Reader) (string, error) {
+	tempFile, err := os.CreateTemp("", "wyoming-upload")
+	if err != nil {
+		return "", err
+	}
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, reader); err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+
+	return tempFile.Name(), nil
+}
+
+// transcribeUploadHTTP transcribes the WAV audio read from reader and returns the transcriptions with the length
+// of the audio. Errors reading the audio are returned with a 400 status, or 413 if it is too large, and errors from
+// the Wyoming server with a 502 status.
+func (g *httpGateway) transcribeUploadHTTP(reader io.Reader, modelName, language string, whole bool) ([]wyoming.Transcription, time.Duration, int, error) {
+	filePath, err := saveUploadHTTP(reader)
+	if err != nil {
+		return nil, 0, requestBodyStatus(err), err
+	}
+	defer os.Remove(filePath)
+
+	// check the audio before connecting to the server
+	WAVFile, audioData, err := wyoming.OpenWAVFile(filePath)
+	if err != nil {
+		return nil, 0, http.StatusBadRequest, err
+	}
+	PCMAudioByteOffset, err := WAVFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		WAVFile.Close()
+		return nil, 0, http.StatusBadRequest, err
+	}
+	WAVFileStat, err := WAVFile.Stat()
+	WAVFile.Close()
+	if err != nil {
+		return nil, 0, http.StatusBadRequest, err
+	}
+	bytesPerSecond := audioData.Rate * audioData.Width * audioData.Channels
+	if bytesPerSecond <= 0 {
+		return nil, 0, http.StatusBadRequest, errors.New("invalid audio format")
+	}
+	duration := time.Duration(float64(WAVFileStat.Size()-PCMAudioByteOffset) / float64(bytesPerSecond) * float64(time.Second))
+
+	options := g.Transcribe
+	options.ModelName = modelName
+	options.Language = language
+	options.Whole = whole
+	transcriptions, err := wyoming.TranscribeFile(filePath, options)
+	if err != nil {
+		return nil, 0, http.StatusBadGateway, err
+	}
+
+	return transcriptions, duration, http.StatusOK, nil
+}
+
+// handleASR handles POST /v1/asr. The body is a WAV file. The "model", "language" and "whole" query parameters
+// are optional.
+func (g *httpGateway) handleASR(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	whole, _ := strconv.ParseBool(query.Get("whole"))
+
+	transcriptions, _, status, err := g.transcribeUploadHTTP(http.MaxBytesReader(w, r.Body, g.MaxBodyBytes), query.Get("model"), query.Get("language"), whole)
+	if err != nil {
+		writeErrorHTTP(w, status, err)
+		return
+	}
+
+	results := make([]transcriptionHTTP, len(transcriptions))
+	for i, transcription := range transcriptions {
+		results[i] = transcriptionHTTP{Text: transcription.Text, Start: transcription.Start.Seconds(), End: transcription.End.Seconds()}
+	}
+	writeJSONHTTP(w, http.StatusOK, results)
+}
+
+// Handler returns the HTTP handler serving every endpoint.
+func (g *httpGateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/tts", g.handleTTS)
+	mux.HandleFunc("POST /v1/asr", g.handleASR)
+	mux.HandleFunc("POST /v1/audio/speech", g.handleOpenAISpeech)
+	mux.HandleFunc("POST /v1/audio/transcriptions", g.handleOpenAITranscriptions)
+	mux.HandleFunc("GET /v1/asr/stream", g.handleASRStream)
+	mux.HandleFunc("GET /v1/tts/stream", g.handleTTSStream)
+	return mux
+}
+
+// addFlagsServeHTTP adds the serve-http flags. The returned function validates them after parsing.
+func addFlagsServeHTTP(currentFlag *flag.FlagSet, globals GlobalOptions) func() (string, httpGateway, *tls.Config, error) {
+	defaults := wyoming.DefaultTranscribeOptions()
+
+	listenAddr := currentFlag.String("listen", ":8080", "address and port to listen for HTTP requests on")
+	ttsAddr := currentFlag.String("tts-addr", "localhost:10200", "address and port for tts Wyoming server")
+	asrAddr := currentFlag.String("asr-addr", defaults.ServerAddr, "address and port for asr Wyoming server")
+	numWorkers := currentFlag.Int("num-workers", defaults.WorkersCount, "number of transcription requests to run at once for each upload")
+	chunkMS := currentFlag.Int("chunk-ms", defaults.ChunkMS, "split uploads longer than this into chunks of this length for whole transcriptions (0 to never split)")
+	chunkOverlapMS := currentFlag.Int("chunk-overlap-ms", defaults.ChunkOverlapMS, "length of audio shared by neighboring chunks when using -chunk-ms")
+	maxBodyMB := currentFlag.Int("max-body-mb", 100, "largest request body to accept in MB")
+	cacheDir := currentFlag.String("cache-dir", "", "directory to cache synthesized audio in")
+	cacheMaxMB := currentFlag.Int("cache-max-mb", 1024, "maximum size of the cache in MB (0 for no limit)")
+	wsOrigins := currentFlag.String("ws-origins", "", "comma separated browser origins allowed to open WebSocket streams (defaults to the same host, \"*\" allows any)")
+
+	parseSegmenterFlags := addSegmenterFlagsASR(currentFlag)
+	parseTLSFlags := addTLSFlags(currentFlag)
+	parseConfigFlags := addConfigFlags(currentFlag, "", globals)
+	parseListenTLSFlags := addListenTLSFlags(currentFlag)
+
+	return func() (string, httpGateway, *tls.Config, error) {
+		if err := parseConfigFlags(); err != nil {
+			return "", httpGateway{}, nil, err
+		}
+		connectOptions, err := parseTLSFlags()
+		if err != nil {
+			return "", httpGateway{}, nil, err
+		}
+		listenTLSConfig, err := parseListenTLSFlags()
+		if err != nil {
+			return "", httpGateway{}, nil, err
+		}
+
+		if *listenAddr == "" {
+			return "", httpGateway{}, nil, errors.New("missing listen address")
+		}
+		if *ttsAddr == "" && *asrAddr == "" {
+			return "", httpGateway{}, nil, errors.New("missing server address")
+		}
+		if *maxBodyMB <= 0 {
+			return "", httpGateway{}, nil, errors.New("max-body-mb must be greater than 0")
+		}
+
+		gateway := httpGateway{
+			TTSAddr:           *ttsAddr,
+			TTSConnectOptions: connectOptions,
+			Transcribe: wyoming.TranscribeOptions{
+				ServerAddr:     *asrAddr,
+				ConnectOptions: connectOptions,
+				WorkersCount:   *numWorkers,
+				ChunkMS:        *chunkMS,
+				ChunkOverlapMS: *chunkOverlapMS,
+				Segmenter:      parseSegmenterFlags(),
+			},
+			MaxBodyBytes: int64(*maxBodyMB) * 1024 * 1024,
+		}
+		gateway.Transcribe.Segmenter.ConnectOptions = connectOptions
+		if *asrAddr != "" {
+			if err := gateway.Transcribe.Validate(); err != nil {
+				return "", httpGateway{}, nil, err
+			}
+		}
+
+		for _, origin := range strings.Split(*wsOrigins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				gateway.WSOrigins = append(gateway.WSOrigins, origin)
+			}
+		}
+
+		if *cacheDir != "" {
+			gateway.TTSCache, err = wyoming.NewTTSCache(*cacheDir, int64(*cacheMaxMB)*1024*1024)
+			if err != nil {
+				return "", httpGateway{}, nil, err
+			}
+		}
+
+		return *listenAddr, gateway, listenTLSConfig, nil
+	}
+}
+
+func parseAndValidateFlagsServeHTTP(currentFlag *flag.FlagSet, globals GlobalOptions) (string, httpGateway, *tls.Config, error) {
+	validateFlags := addFlagsServeHTTP(currentFlag, globals)
+	currentFlag.Parse(os.Args[2:])
+	return validateFlags()
+}
+
+// flagSetServeHTTP returns the flag set of serve-http without parsing it.
+func flagSetServeHTTP([]string) *flag.FlagSet {
+	currentFlag := newFlagSet("serve-http")
+	addFlagsServeHTTP(currentFlag, GlobalOptions{})
+	return currentFlag
+}
+
+// ServeHTTP runs an HTTP server exposing the Wyoming servers as REST endpoints.
+func ServeHTTP(globals GlobalOptions) error {
+	currentFlag := newFlagSet("serve-http")
+	listenAddr, gateway, listenTLSConfig, err := parseAndValidateFlagsServeHTTP(currentFlag, globals)
+	if err != nil {
+		return err
+	}
+
+	server := http.Server{
+		Addr:              listenAddr,
+		Handler:           gateway.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         listenTLSConfig,
+	}
+
+	if listenTLSConfig != nil {
+		// the certificate is already loaded into TLSConfig
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
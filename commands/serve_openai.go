@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+// openAISpeechRequest is the JSON body of a POST /v1/audio/speech request. "model", "instructions" and "speed"
+// are accepted but not used.
+type openAISpeechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format"`
+	Speed          float64 `json:"speed"`
+}
+
+// openAIVoices are the voices of the OpenAI API. Clients often send one of them without knowing the Wyoming voices.
+var openAIVoices = map[string]bool{
+	"alloy": true, "ash": true, "ballad": true, "coral": true, "echo": true, "fable": true, "nova": true,
+	"onyx": true, "sage": true, "shimmer": true, "verse": true,
+}
+
+// openAISegment is a segment of a "verbose_json" transcription. Fields the Wyoming servers do not report are zero.
+type openAISegment struct {
+	ID               int     `json:"id"`
+	Seek             int     `json:"seek"`
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
+	Text             string  `json:"text"`
+	Tokens           []int   `json:"tokens"`
+	Temperature      float64 `json:"temperature"`
+	AvgLogprob       float64 `json:"avg_logprob"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	NoSpeechProb     float64 `json:"no_speech_prob"`
+}
+
+// openAIVerboseTranscription is the "verbose_json" transcription response.
+type openAIVerboseTranscription struct {
+	Task     string          `json:"task"`
+	Language string          `json:"language"`
+	Duration float64         `json:"duration"`
+	Text     string          `json:"text"`
+	Segments []openAISegment `json:"segments"`
+}
+
+// writeOpenAIErrorHTTP writes err in the error format of the OpenAI API.
+func writeOpenAIErrorHTTP(w http.ResponseWriter, status int, err error) {
+	writeOpenAIParamErrorHTTP(w, status, "", err)
+}
+
+// writeOpenAIParamErrorHTTP writes err caused by the request parameter param in the error format of the OpenAI API.
+func writeOpenAIParamErrorHTTP(w http.ResponseWriter, status int, param string, err error) {
+	if status >= http.StatusInternalServerError {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	errorType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errorType = "server_error"
+	}
+	var errorParam any
+	if param != "" {
+		errorParam = param
+	}
+	writeJSONHTTP(w, status, map[string]any{
+		"error": map[string]any{"message": err.Error(), "type": errorType, "param": errorParam, "code": nil},
+	})
+}
+
+// handleOpenAISpeech handles POST /v1/audio/speech. Only the "wav" and "pcm" response formats are supported. The
+// OpenAI API defaults to "mp3", which can't be encoded here, so "wav" is sent when no format is given and other
+// formats are rejected with an error naming the supported ones. OpenAI voices that the Wyoming server does not
+// report, such as "alloy", use the server's default voice and other unknown voices are rejected. "pcm" audio is sent
+// at the rate of the Wyoming voice.
+func (g *httpGateway) handleOpenAISpeech(w http.ResponseWriter, r *http.Request) {
+	var request openAISpeechRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, g.MaxBodyBytes)).Decode(&request); err != nil {
+		writeOpenAIErrorHTTP(w, requestBodyStatus(err), err)
+		return
+	}
+	if request.Input == "" {
+		writeOpenAIErrorHTTP(w, http.StatusBadRequest, errors.New("missing input"))
+		return
+	}
+
+	format := AUDIO_FORMAT_WAV
+	switch request.ResponseFormat {
+	case "", "wav":
+	case "pcm":
+		format = AUDIO_FORMAT_RAW
+	default:
+		writeOpenAIParamErrorHTTP(w, http.StatusBadRequest, "response_format", fmt.Errorf("unsupported response_format %q, supported formats are: wav, pcm", request.ResponseFormat))
+		return
+	}
+
+	voiceData := wyoming.SynthesizeVoiceData{Name: request.Voice}
+	g.synthesizeHTTP(w, request.Input, voiceData, openAIVoices, format, writeOpenAIErrorHTTP)
+}
+
+// handleOpenAITranscriptions handles POST /v1/audio/transcriptions. The "file" must be a 16-bit WAV file. The
+// "model", "prompt" and "temperature" fields are accepted but not used.
+func (g *httpGateway) handleOpenAITranscriptions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, g.MaxBodyBytes)
+	if err := r.ParseMultipartForm(32 * 1024 * 1024); err != nil {
+		writeOpenAIErrorHTTP(w, requestBodyStatus(err), err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeOpenAIErrorHTTP(w, http.StatusBadRequest, errors.New("missing file"))
+		return
+	}
+	defer file.Close()
+
+	responseFormat := r.FormValue("response_format")
+	if responseFormat == "" {
+		responseFormat = "json"
+	}
+	switch responseFormat {
+	case "json", "text", "srt", "vtt", "verbose_json":
+	default:
+		writeOpenAIErrorHTTP(w, http.StatusBadRequest, fmt.Errorf("unsupported response_format %q", responseFormat))
+		return
+	}
+
+	language := r.FormValue("language")
+	transcriptions, duration, status, err := g.transcribeUploadHTTP(file, "", language, false)
+	if err != nil {
+		writeOpenAIErrorHTTP(w, status, err)
+		return
+	}
+
+	var texts []string
+	cues := make([]utils.SubtitleCue, len(transcriptions))
+	segments := make([]openAISegment, len(transcriptions))
+	for i, transcription := range transcriptions {
+		texts = append(texts, transcription.Text)
+		cues[i] = utils.SubtitleCue{Start: transcription.Start, End: transcription.End, Text: transcription.Text}
+		segments[i] = openAISegment{ID: i, Start: transcription.Start.Seconds(), End: transcription.End.Seconds(), Text: transcription.Text, Tokens: []int{}}
+	}
+	text := strings.Join(texts, " ")
+
+	switch responseFormat {
+	case "json":
+		writeJSONHTTP(w, http.StatusOK, map[string]string{"text": text})
+	case "verbose_json":
+		writeJSONHTTP(w, http.StatusOK, openAIVerboseTranscription{
+			Task:     "transcribe",
+			Language: language,
+			Duration: duration.Seconds(),
+			Text:     text,
+			Segments: segments,
+		})
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, text)
+	case "srt":
+		w.Header().Set("Content-Type", "application/x-subrip; charset=utf-8")
+		fmt.Fprint(w, utils.FormatSRT(cues))
+	case "vtt":
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		fmt.Fprint(w, utils.FormatVTT(cues))
+	}
+}
@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/john-pettigrew/wyoming-cli/websocket"
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+// wsMessage is a JSON control message sent over a WebSocket stream. Audio is sent as binary messages.
+type wsMessage struct {
+	Type string `json:"type"`
+
+	// audio-start
+	Rate     int    `json:"rate,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Channels int    `json:"channels,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Language string `json:"language,omitempty"`
+
+	// synthesize
+	Text    string `json:"text,omitempty"`
+	Voice   string `json:"voice,omitempty"`
+	Speaker string `json:"speaker,omitempty"`
+
+	// error
+	Message string `json:"message,omitempty"`
+}
+
+// writeJSONWS sends message as a text message.
+func writeJSONWS(conn *websocket.Conn, message wsMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TEXT_MESSAGE, data)
+}
+
+// writeErrorWS sends err as an "error" message.
+func writeErrorWS(conn *websocket.Conn, err error) error {
+	return writeJSONWS(conn, wsMessage{Type: "error", Message: err.Error()})
+}
+
+// checkOriginWS accepts requests without an Origin header, such as those from non-browser clients. Browser requests
+// must come from one of WSOrigins, or from the same host when WSOrigins is empty. "*" accepts every origin.
+func (g *httpGateway) checkOriginWS(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(g.WSOrigins) == 0 {
+		originURL, err := url.Parse(origin)
+		return err == nil && originURL.Host == r.Host
+	}
+
+	return slices.Contains(g.WSOrigins, "*") || slices.Contains(g.WSOrigins, origin)
+}
+
+// asrStreamWS is a single transcription running on an ASR server while audio arrives over a WebSocket.
+type asrStreamWS struct {
+	wyomingConn wyoming.WyomingConnection
+	audioWriter *io.PipeWriter
+	result      chan asrStreamResultWS
+}
+
+type asrStreamResultWS struct {
+	Text string
+	Err  error
+}
+
+// startASRStreamWS connects to the ASR server and starts a transcription of the audio written to the returned
+// stream.
+func (g *httpGateway) startASRStreamWS(message wsMessage) (*asrStreamWS, error) {
+	if message.Rate <= 0 || message.Channels <= 0 {
+		return nil, errors.New("audio-start must include rate and channels")
+	}
+	if message.Width == 0 {
+		message.Width = 2
+	}
+
+	wyomingConn, err := wyoming.ConnectWithOptions(g.Transcribe.ServerAddr, g.Transcribe.ConnectOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	audioReader, audioWriter := io.Pipe()
+	stream := asrStreamWS{wyomingConn: wyomingConn, audioWriter: audioWriter, result: make(chan asrStreamResultWS, 1)}
+	audioData := wyoming.WyomingAudioData{Rate: message.Rate, Width: message.Width, Channels: message.Channels}
+
+	go func() {
+		text, err := wyomingConn.TranscribeAudio(audioReader, audioData, message.Model, message.Language)
+		// unblock writes if the server failed before the audio was finished
+		audioReader.CloseWithError(errors.New("transcription stopped"))
+		stream.result <- asrStreamResultWS{Text: text, Err: err}
+	}()
+
+	return &stream, nil
+}
+
+// finish stops the audio and waits for the transcript.
+func (s *asrStreamWS) finish() (string, error) {
+	s.audioWriter.Close()
+	result := <-s.result
+	s.wyomingConn.Disconnect()
+	return result.Text, result.Err
+}
+
+// abort stops the transcription without waiting for a transcript.
+func (s *asrStreamWS) abort() {
+	s.audioWriter.CloseWithError(errors.New("stream closed"))
+	s.wyomingConn.Disconnect()
+}
+
+// handleASRStream handles GET /v1/asr/stream. Each transcription starts with an "audio-start" message, followed by
+// binary messages of PCM audio and ends with an "audio-stop" message, which is answered with a "transcript"
+// message. Several transcriptions may be sent over the same socket.
+func (g *httpGateway) handleASRStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r, g.checkOriginWS)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var stream *asrStreamWS
+	defer func() {
+		if stream != nil {
+			stream.abort()
+		}
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if messageType == websocket.BINARY_MESSAGE {
+			if stream == nil {
+				writeErrorWS(conn, errors.New("audio received before audio-start"))
+				continue
+			}
+			if _, err := stream.audioWriter.Write(data); err != nil {
+				// the server failed, report its error
+				_, err := stream.finish()
+				stream = nil
+				writeErrorWS(conn, err)
+			}
+			continue
+		}
+
+		var message wsMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			writeErrorWS(conn, err)
+			continue
+		}
+
+		switch message.Type {
+		case wyoming.AudioStartMessageType:
+			if stream != nil {
+				writeErrorWS(conn, errors.New("audio-start received before audio-stop"))
+				continue
+			}
+			stream, err = g.startASRStreamWS(message)
+			if err != nil {
+				writeErrorWS(conn, err)
+			}
+		case wyoming.AudioStopMessageType:
+			if stream == nil {
+				writeErrorWS(conn, errors.New("audio-stop received before audio-start"))
+				continue
+			}
+			text, err := stream.finish()
+			stream = nil
+			if err != nil {
+				writeErrorWS(conn, err)
+				continue
+			}
+			if err := writeJSONWS(conn, wsMessage{Type: wyoming.TranscriptMessageType, Text: text}); err != nil {
+				return
+			}
+		default:
+			writeErrorWS(conn, fmt.Errorf("unknown message type %q", message.Type))
+		}
+	}
+}
+
+// wsAudioWriter sends audio as binary messages after an "audio-start" message describing its format.
+type wsAudioWriter struct {
+	Conn *websocket.Conn
+
+	started bool
+}
+
+func (a *wsAudioWriter) WriteAudioFormat(audioData wyoming.WyomingAudioData) error {
+	if a.started {
+		return nil
+	}
+	a.started = true
+	return writeJSONWS(a.Conn, wsMessage{Type: wyoming.AudioStartMessageType, Rate: audioData.Rate, Width: audioData.Width, Channels: audioData.Channels})
+}
+
+func (a *wsAudioWriter) Write(p []byte) (int, error) {
+	if err := a.Conn.WriteMessage(websocket.BINARY_MESSAGE, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// synthesizeWS synthesizes the text in message to conn as it is received from the TTS server.
+func (g *httpGateway) synthesizeWS(conn *websocket.Conn, message wsMessage) error {
+	if message.Text == "" {
+		return errors.New("missing text")
+	}
+
+	wyomingConn, err := wyoming.ConnectWithOptions(g.TTSAddr, g.TTSConnectOptions)
+	if err != nil {
+		return err
+	}
+	defer wyomingConn.Disconnect()
+	wyomingConn.TTSCache = g.TTSCache
+
+	voiceData := wyoming.SynthesizeVoiceData{Name: message.Voice, Speaker: message.Speaker, Language: message.Language}
+	if err := wyomingConn.ValidateVoice(voiceData); err != nil {
+		return err
+	}
+
+	audioWriter := wsAudioWriter{Conn: conn}
+	audioData, err := wyomingConn.SynthesizeAudio(message.Text, voiceData, &audioWriter)
+	if err != nil {
+		return err
+	}
+
+	// no audio was received
+	if err := audioWriter.WriteAudioFormat(audioData); err != nil {
+		return err
+	}
+	return writeJSONWS(conn, wsMessage{Type: wyoming.AudioStopMessageType})
+}
+
+// handleTTSStream handles GET /v1/tts/stream. Each "synthesize" message is answered with an "audio-start" message,
+// binary messages of PCM audio as they arrive from the TTS server and an "audio-stop" message.
+func (g *httpGateway) handleTTSStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r, g.checkOriginWS)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.TEXT_MESSAGE {
+			writeErrorWS(conn, errors.New("expected a synthesize message"))
+			continue
+		}
+
+		var message wsMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			writeErrorWS(conn, err)
+			continue
+		}
+		if message.Type != wyoming.SynthesizeMessageType {
+			writeErrorWS(conn, fmt.Errorf("unknown message type %q", message.Type))
+			continue
+		}
+
+		if err := g.synthesizeWS(conn, message); err != nil {
+			writeErrorWS(conn, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+// readCertPoolTLS returns a certificate pool with the PEM certificates in the file at filePath.
+func readCertPoolTLS(filePath string) (*x509.CertPool, error) {
+	PEMData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(PEMData) {
+		return nil, errors.New("no certificates found in " + filePath)
+	}
+	return pool, nil
+}
+
+// addTLSFlags adds the flags used to connect to Wyoming servers with "tcps://" and "wss://" addresses. The returned
+// function must be called after parsing and returns the options to connect with.
+func addTLSFlags(currentFlag *flag.FlagSet) func() (wyoming.ConnectOptions, error) {
+	caFile := currentFlag.String("tls-ca", "", "PEM file with the CA certificates used to verify Wyoming servers (defaults to the system CAs)")
+	certFile := currentFlag.String("tls-cert", "", "PEM file with a client certificate to send to Wyoming servers")
+	keyFile := currentFlag.String("tls-key", "", "PEM file with the private key for -tls-cert")
+	serverName := currentFlag.String("tls-server-name", "", "server name to verify instead of the host in the server address")
+	insecureSkipVerify := currentFlag.Bool("tls-insecure-skip-verify", false, "do not verify server certificates")
+
+	return func() (wyoming.ConnectOptions, error) {
+		if (*certFile == "") != (*keyFile == "") {
+			return wyoming.ConnectOptions{}, errors.New("tls-cert and tls-key must be used together")
+		}
+
+		config := tls.Config{ServerName: *serverName, InsecureSkipVerify: *insecureSkipVerify}
+
+		if *caFile != "" {
+			pool, err := readCertPoolTLS(*caFile)
+			if err != nil {
+				return wyoming.ConnectOptions{}, err
+			}
+			config.RootCAs = pool
+		}
+
+		if *certFile != "" {
+			certificate, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+			if err != nil {
+				return wyoming.ConnectOptions{}, err
+			}
+			config.Certificates = []tls.Certificate{certificate}
+		}
+
+		return wyoming.ConnectOptions{TLSConfig: &config}, nil
+	}
+}
+
+// addListenTLSFlags adds the flags used to serve over TLS. The returned function must be called after parsing and
+// returns nil if TLS is not enabled.
+func addListenTLSFlags(currentFlag *flag.FlagSet) func() (*tls.Config, error) {
+	certFile := currentFlag.String("listen-tls-cert", "", "PEM file with the certificate to serve TLS with")
+	keyFile := currentFlag.String("listen-tls-key", "", "PEM file with the private key for -listen-tls-cert")
+	clientCAFile := currentFlag.String("listen-tls-client-ca", "", "PEM file with the CA certificates clients must present a certificate from (mutual TLS)")
+
+	return func() (*tls.Config, error) {
+		if *certFile == "" && *keyFile == "" {
+			if *clientCAFile != "" {
+				return nil, errors.New("listen-tls-client-ca requires listen-tls-cert and listen-tls-key")
+			}
+			return nil, nil
+		}
+		if *certFile == "" || *keyFile == "" {
+			return nil, errors.New("listen-tls-cert and listen-tls-key must be used together")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config := tls.Config{Certificates: []tls.Certificate{certificate}}
+
+		if *clientCAFile != "" {
+			pool, err := readCertPoolTLS(*clientCAFile)
+			if err != nil {
+				return nil, err
+			}
+			config.ClientCAs = pool
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+
+		return &config, nil
+	}
+}
@@ -3,18 +3,35 @@ package commands
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/john-pettigrew/wyoming-cli/wyoming"
 )
 
-func validateInputsTTS(text, serverAddr, outputFilePath string, outputRawData bool) error {
+func validateInputsTTS(text, outputFilePath string, outputRawData bool, batchFilePath string, listVoices bool, options wyoming.SynthesizeOptions) error {
+	if options.ServerAddr == "" {
+		return errors.New("missing server address")
+	}
+	if listVoices {
+		return nil
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	if batchFilePath != "" {
+		if text != "" || outputFilePath != "" || outputRawData {
+			return errors.New("batch cannot be used with text, output_file or output-raw")
+		}
+		if options.Split || options.SSML {
+			return errors.New("batch cannot be used with split or ssml")
+		}
+		return nil
+	}
 	if text == "" {
 		return errors.New("missing text")
 	}
-	if serverAddr == "" {
-		return errors.New("missing server address")
-	}
 	if !outputRawData {
 		if outputFilePath == "" {
 			return errors.New("missing output file path")
@@ -31,52 +48,181 @@ func validateInputsTTS(text, serverAddr, outputFilePath string, outputRawData bo
 	return nil
 }
 
-func parseAndValidateFlagsTTS(currentFlag *flag.FlagSet) (string, string, string, string, bool, error) {
+// ttsCommandArgs holds the values of the tts flags.
+type ttsCommandArgs struct {
+	Text           string
+	OutputFilePath string
+	OutputRawData  bool
+	BatchFilePath  string
+	OutputDir      string
+	Force          bool
+	ListVoices     bool
+	Options        wyoming.SynthesizeOptions
+}
+
+// addFlagsTTS adds the tts flags. The returned function validates them after parsing.
+func addFlagsTTS(currentFlag *flag.FlagSet, globals GlobalOptions) func() (ttsCommandArgs, error) {
+	defaults := wyoming.DefaultSynthesizeOptions()
+
 	text := currentFlag.String("text", "", "text to be spoken")
-	serverAddr := currentFlag.String("addr", "localhost:10200", "address and port for tts Wyoming server")
+	textFilePath := currentFlag.String("text_file", "", "file containing the text to be spoken")
+	serverAddr := currentFlag.String("addr", defaults.ServerAddr, "address and port for tts Wyoming server (\"auto\" or \"mdns:<name>\" to find one with mDNS)")
 	outputFilePath := currentFlag.String("output_file", "", "output file path")
 	outputRawData := currentFlag.Bool("output-raw", false, "stream audio data to stdout")
 
 	voiceName := currentFlag.String("voice-name", "", "voice name")
+	speaker := currentFlag.String("speaker", "", "speaker name for voices with multiple speakers")
+	language := currentFlag.String("language", "", "language of the voice")
+	listVoices := currentFlag.Bool("list-voices", false, "list the voices, languages and speakers reported by the server")
+
+	batchFilePath := currentFlag.String("batch", "", "CSV, JSONL or text file of prompts to synthesize, one per row")
+	outputDir := currentFlag.String("output-dir", ".", "directory to write \"<id>.wav\" files to when using -batch")
+	numWorkers := currentFlag.Int("num-workers", defaults.WorkersCount, "number of prompts or text chunks to synthesize at the same time when using -batch or -split")
+	force := currentFlag.Bool("force", false, "overwrite existing files when using -batch")
+
+	splitText := currentFlag.Bool("split", defaults.Split, "split long text into sentences and paragraphs that are synthesized separately and joined")
+	maxChars := currentFlag.Int("max-chars", defaults.MaxChars, "maximum number of characters in each chunk of text when using -split (0 for one sentence per chunk)")
+	sentenceSilenceMS := currentFlag.Int("sentence-silence-ms", defaults.SentenceSilenceMS, "length of silence between sentences when using -split or -ssml")
+	paragraphSilenceMS := currentFlag.Int("paragraph-silence-ms", defaults.ParagraphSilenceMS, "length of silence between paragraphs when using -split or -ssml")
+
+	cacheDir := currentFlag.String("cache-dir", "", "directory to cache synthesized audio in so repeated text is not synthesized again")
+	cacheMaxMB := currentFlag.Int("cache-max-mb", 1024, "maximum size of the cache in MB before the least recently used audio is removed (0 for no limit)")
+
+	ssml := currentFlag.Bool("ssml", defaults.SSML, "read the text as SSML supporting <speak>, <break>, <s>, <p>, <voice> and <say-as>")
+
+	parseTLSFlags := addTLSFlags(currentFlag)
+	parseConfigFlags := addConfigFlags(currentFlag, SERVICE_TTS, globals)
+
+	return func() (ttsCommandArgs, error) {
+		if err := parseConfigFlags(); err != nil {
+			return ttsCommandArgs{}, err
+		}
+		connectOptions, err := parseTLSFlags()
+		if err != nil {
+			return ttsCommandArgs{}, err
+		}
+
+		if *textFilePath != "" {
+			if *text != "" {
+				return ttsCommandArgs{}, errors.New("text and text_file cannot be used together")
+			}
+			textData, err := os.ReadFile(*textFilePath)
+			if err != nil {
+				return ttsCommandArgs{}, err
+			}
+			*text = string(textData)
+		}
+
+		options := wyoming.SynthesizeOptions{
+			ServerAddr:         *serverAddr,
+			ConnectOptions:     connectOptions,
+			Voice:              wyoming.SynthesizeVoiceData{Name: *voiceName, Speaker: *speaker, Language: *language},
+			Split:              *splitText,
+			MaxChars:           *maxChars,
+			SSML:               *ssml,
+			SentenceSilenceMS:  *sentenceSilenceMS,
+			ParagraphSilenceMS: *paragraphSilenceMS,
+			WorkersCount:       *numWorkers,
+		}
+
+		if err := validateInputsTTS(*text, *outputFilePath, *outputRawData, *batchFilePath, *listVoices, options); err != nil {
+			return ttsCommandArgs{}, err
+		}
+
+		if *cacheMaxMB < 0 {
+			return ttsCommandArgs{}, errors.New("cache-max-mb must not be negative")
+		}
+
+		if *cacheDir != "" {
+			var err error
+			options.Cache, err = wyoming.NewTTSCache(*cacheDir, int64(*cacheMaxMB)*1024*1024)
+			if err != nil {
+				return ttsCommandArgs{}, err
+			}
+		}
 
+		return ttsCommandArgs{
+			Text:           *text,
+			OutputFilePath: *outputFilePath,
+			OutputRawData:  *outputRawData,
+			BatchFilePath:  *batchFilePath,
+			OutputDir:      *outputDir,
+			Force:          *force,
+			ListVoices:     *listVoices,
+			Options:        options,
+		}, nil
+	}
+}
+
+func parseAndValidateFlagsTTS(currentFlag *flag.FlagSet, globals GlobalOptions) (ttsCommandArgs, error) {
+	validateFlags := addFlagsTTS(currentFlag, globals)
 	currentFlag.Parse(os.Args[2:])
+	return validateFlags()
+}
 
-	if err := validateInputsTTS(*text, *serverAddr, *outputFilePath, *outputRawData); err != nil {
-		return "", "", "", "", false, err
+// flagSetTTS returns the flag set of tts without parsing it.
+func flagSetTTS([]string) *flag.FlagSet {
+	currentFlag := newFlagSet("tts")
+	addFlagsTTS(currentFlag, GlobalOptions{})
+	return currentFlag
+}
+
+// printVoicesTTS prints the voices reported by the Wyoming server with their languages and speakers.
+func printVoicesTTS(wyomingConn wyoming.WyomingConnection) error {
+	voices := wyomingConn.ListVoices()
+	if len(voices) == 0 {
+		return errors.New("server did not report any voices")
 	}
 
-	return *text, *serverAddr, *outputFilePath, *voiceName, *outputRawData, nil
+	for _, voice := range voices {
+		line := voice.Name
+		if len(voice.Languages) > 0 {
+			line += "\tlanguages: " + strings.Join(voice.Languages, ", ")
+		}
+		if len(voice.Speakers) > 0 {
+			line += "\tspeakers: " + strings.Join(voice.Speakers, ", ")
+		}
+		if _, err := fmt.Println(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func TTS() error {
-	currentFlag := flag.NewFlagSet("tts", flag.ExitOnError)
+func TTS(globals GlobalOptions) error {
+	currentFlag := newFlagSet("tts")
 
-	text, serverAddr, outputFilePath, voiceName, outputRawData, err := parseAndValidateFlagsTTS(currentFlag)
+	args, err := parseAndValidateFlagsTTS(currentFlag, globals)
 	if err != nil {
 		return err
 	}
+	options := args.Options
 
-	// connect to server
-	wyomingConn, err := wyoming.Connect(serverAddr)
+	options.ServerAddr, err = resolveServerAddr(options.ServerAddr, SERVICE_TTS)
 	if err != nil {
 		return err
 	}
-	defer wyomingConn.Disconnect()
 
-	// synthesize audio
-	if outputRawData {
-		err = wyomingConn.SynthesizeAudioToStdout(text, wyoming.SynthesizeVoiceData{Name: voiceName})
+	if args.BatchFilePath != "" {
+		return synthesizeBatchTTS(args.BatchFilePath, args.OutputDir, args.Force, options)
+	}
+
+	if args.ListVoices {
+		wyomingConn, err := wyoming.ConnectWithOptions(options.ServerAddr, options.ConnectOptions)
 		if err != nil {
 			return err
 		}
+		defer wyomingConn.Disconnect()
 
-		return nil
+		return printVoicesTTS(wyomingConn)
 	}
 
-	err = wyomingConn.SynthesizeAudioToWAVFile(text, wyoming.SynthesizeVoiceData{Name: voiceName}, outputFilePath)
-	if err != nil {
+	// synthesize audio
+	if args.OutputRawData {
+		_, err = wyoming.Synthesize(args.Text, options, os.Stdout)
 		return err
 	}
 
-	return nil
+	return wyoming.SynthesizeToWAVFile(args.Text, options, args.OutputFilePath)
 }
@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/john-pettigrew/wyoming-cli/wyoming"
+)
+
+// batchItemTTS is one prompt to synthesize in a batch.
+type batchItemTTS struct {
+	ID       string `json:"id"`
+	Text     string `json:"text"`
+	Voice    string `json:"voice"`
+	Speaker  string `json:"speaker"`
+	Language string `json:"language"`
+}
+
+// batchFailureTTS records a prompt that could not be synthesized.
+type batchFailureTTS struct {
+	ID  string
+	Err error
+}
+
+// validateBatchIDTTS makes sure id can be used as a file name inside the output directory.
+func validateBatchIDTTS(id string) error {
+	if id == "" {
+		return errors.New("missing id")
+	}
+	if id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid id %q", id)
+	}
+
+	return nil
+}
+
+// readBatchCSVTTS reads prompts from CSV rows of id, text and optionally voice, speaker and language. A first row
+// starting with "id" is treated as a header and may list the columns in any order.
+func readBatchCSVTTS(reader io.Reader) ([]batchItemTTS, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	rows, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{"id": 0, "text": 1, "voice": 2, "speaker": 3, "language": 4}
+	if len(rows) > 0 && len(rows[0]) > 0 && strings.EqualFold(strings.TrimSpace(rows[0][0]), "id") {
+		columns = map[string]int{}
+		for i, name := range rows[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := columns["text"]; !ok {
+			return nil, errors.New("CSV header is missing a text column")
+		}
+		rows = rows[1:]
+	}
+
+	column := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var items []batchItemTTS
+	for _, row := range rows {
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		items = append(items, batchItemTTS{
+			ID:       column(row, "id"),
+			Text:     column(row, "text"),
+			Voice:    column(row, "voice"),
+			Speaker:  column(row, "speaker"),
+			Language: column(row, "language"),
+		})
+	}
+
+	return items, nil
+}
+
+// readBatchJSONLTTS reads prompts from JSON objects with "id", "text", "voice", "speaker" and "language" keys, one
+// per line.
+func readBatchJSONLTTS(reader io.Reader) ([]batchItemTTS, error) {
+	var items []batchItemTTS
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var item batchItemTTS
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		items = append(items, item)
+	}
+
+	return items, scanner.Err()
+}
+
+// readBatchTextTTS reads one prompt per line. Prompts are named after their line number.
+func readBatchTextTTS(reader io.Reader) ([]batchItemTTS, error) {
+	var items []batchItemTTS
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		items = append(items, batchItemTTS{ID: fmt.Sprintf("%04d", lineNumber), Text: line})
+	}
+
+	return items, scanner.Err()
+}
+
+// readBatchTTS reads the prompts in the batch file located at batchFilePath. The format is chosen from the file
+// extension: ".csv", ".jsonl" or plain text for anything else.
+func readBatchTTS(batchFilePath string) ([]batchItemTTS, error) {
+	f, err := os.Open(batchFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []batchItemTTS
+	switch strings.ToLower(filepath.Ext(batchFilePath)) {
+	case ".csv":
+		items, err = readBatchCSVTTS(f)
+	case ".jsonl", ".ndjson":
+		items, err = readBatchJSONLTTS(f)
+	default:
+		items, err = readBatchTextTTS(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("batch file has no prompts")
+	}
+
+	seen := map[string]bool{}
+	for i, item := range items {
+		if err := validateBatchIDTTS(item.ID); err != nil {
+			return nil, fmt.Errorf("prompt %d: %w", i+1, err)
+		}
+		if item.Text == "" {
+			return nil, fmt.Errorf("prompt %q: missing text", item.ID)
+		}
+		if seen[item.ID] {
+			return nil, fmt.Errorf("prompt %q: duplicate id", item.ID)
+		}
+		seen[item.ID] = true
+	}
+
+	return items, nil
+}
+
+func synthesizeBatchItemTTS(item batchItemTTS, outputFilePath string, options wyoming.SynthesizeOptions) error {
+	options.Voice = wyoming.SynthesizeVoiceData{Name: item.Voice, Speaker: item.Speaker, Language: item.Language}
+
+	// write to a temporary file first so that an existing file is only replaced once the new audio is ready
+	tempFilePath := filepath.Join(filepath.Dir(outputFilePath), "."+filepath.Base(outputFilePath)+".tmp")
+	os.Remove(tempFilePath)
+	if err := wyoming.SynthesizeToWAVFile(item.Text, options, tempFilePath); err != nil {
+		os.Remove(tempFilePath)
+		return err
+	}
+
+	return os.Rename(tempFilePath, outputFilePath)
+}
+
+// synthesizeBatchTTS synthesizes each prompt in batchFilePath to "<outputDir>/<id>.wav" using options.WorkersCount
+// connections. Existing files are skipped unless force is set. The voice, speaker and language in options.Voice are
+// used for prompts that do not set their own.
+func synthesizeBatchTTS(batchFilePath, outputDir string, force bool, options wyoming.SynthesizeOptions) error {
+	items, err := readBatchTTS(batchFilePath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	itemsChan := make(chan batchItemTTS)
+	failuresChan := make(chan batchFailureTTS)
+	var wg sync.WaitGroup
+	var skippedCount, createdCount int
+	var countsLock sync.Mutex
+
+	// each connection to a stdio:// server starts a new server process
+	for i := 0; i < wyoming.ConnectionWorkers(options.ServerAddr, options.WorkersCount); i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range itemsChan {
+				outputFilePath := filepath.Join(outputDir, item.ID+".wav")
+				if !force {
+					if _, err := os.Stat(outputFilePath); err == nil {
+						countsLock.Lock()
+						skippedCount += 1
+						countsLock.Unlock()
+						continue
+					}
+				}
+
+				if item.Voice == "" {
+					item.Voice = options.Voice.Name
+				}
+				if item.Speaker == "" {
+					item.Speaker = options.Voice.Speaker
+				}
+				if item.Language == "" {
+					item.Language = options.Voice.Language
+				}
+				if err := synthesizeBatchItemTTS(item, outputFilePath, options); err != nil {
+					failuresChan <- batchFailureTTS{ID: item.ID, Err: err}
+					continue
+				}
+
+				countsLock.Lock()
+				createdCount += 1
+				countsLock.Unlock()
+			}
+		}()
+	}
+
+	go func() {
+		for _, item := range items {
+			itemsChan <- item
+		}
+		close(itemsChan)
+		wg.Wait()
+		close(failuresChan)
+	}()
+
+	var failures []batchFailureTTS
+	for failure := range failuresChan {
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].ID < failures[j].ID })
+
+	fmt.Printf("%d created, %d skipped, %d failed\n", createdCount, skippedCount, len(failures))
+	for _, failure := range failures {
+		fmt.Printf("failed %s: %s\n", failure.ID, failure.Err)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d prompts failed", len(failures), len(items))
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBatchCSVTTS(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []batchItemTTS
+	}{
+		{
+			name:  "no header",
+			input: "one,Hello there.\ntwo, \"Hi, again.\",en_US-amy,b\n\n",
+			want: []batchItemTTS{
+				{ID: "one", Text: "Hello there."},
+				{ID: "two", Text: "Hi, again.", Voice: "en_US-amy", Speaker: "b"},
+			},
+		},
+		{
+			name:  "header",
+			input: "ID,voice,text,language\none,de_DE-thorsten,Hallo.,de_DE\n",
+			want:  []batchItemTTS{{ID: "one", Text: "Hallo.", Voice: "de_DE-thorsten", Language: "de_DE"}},
+		},
+		{
+			name:  "short rows",
+			input: "id,text,speaker\none\n",
+			want:  []batchItemTTS{{ID: "one"}},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := readBatchCSVTTS(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("%s: readBatchCSVTTS returned error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: readBatchCSVTTS = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	if _, err := readBatchCSVTTS(strings.NewReader("id,voice\none,en_US-amy\n")); err == nil {
+		t.Errorf("readBatchCSVTTS with a header missing the text column returned no error")
+	}
+}
+
+func TestReadBatchJSONLTTS(t *testing.T) {
+	input := `{"id": "one", "text": "Hello there."}
+
+{"id": "two", "text": "Hi.", "voice": "en_US-amy", "speaker": "a", "language": "en_US"}
+`
+	want := []batchItemTTS{
+		{ID: "one", Text: "Hello there."},
+		{ID: "two", Text: "Hi.", Voice: "en_US-amy", Speaker: "a", Language: "en_US"},
+	}
+
+	got, err := readBatchJSONLTTS(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readBatchJSONLTTS returned error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readBatchJSONLTTS = %+v, want %+v", got, want)
+	}
+
+	_, err = readBatchJSONLTTS(strings.NewReader("{\"id\": \"one\", \"text\": \"Hi.\"}\n{\"id\": \n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("readBatchJSONLTTS with invalid JSON returned error %v, want an error for line 2", err)
+	}
+}
+
+func TestReadBatchTextTTS(t *testing.T) {
+	got, err := readBatchTextTTS(strings.NewReader("Hello there.\n\n  Hi again.  \n"))
+	if err != nil {
+		t.Fatalf("readBatchTextTTS returned error %v", err)
+	}
+
+	want := []batchItemTTS{{ID: "0001", Text: "Hello there."}, {ID: "0003", Text: "Hi again."}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readBatchTextTTS = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidateBatchIDTTS(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{id: "prompt-1", valid: true},
+		{id: "0001.intro", valid: true},
+		{id: "", valid: false},
+		{id: ".", valid: false},
+		{id: "..", valid: false},
+		{id: "../prompt", valid: false},
+		{id: `dir\prompt`, valid: false},
+	}
+
+	for _, test := range tests {
+		err := validateBatchIDTTS(test.id)
+		if (err == nil) != test.valid {
+			t.Errorf("validateBatchIDTTS(%q) = %v, want valid %v", test.id, err, test.valid)
+		}
+	}
+}
+
+func TestReadBatchTTS(t *testing.T) {
+	tests := []struct {
+		fileName string
+		content  string
+		wantIDs  []string
+		wantErr  bool
+	}{
+		{fileName: "prompts.csv", content: "one,Hello.\ntwo,Hi.\n", wantIDs: []string{"one", "two"}},
+		{fileName: "prompts.JSONL", content: "{\"id\": \"one\", \"text\": \"Hello.\"}\n", wantIDs: []string{"one"}},
+		{fileName: "prompts.ndjson", content: "{\"id\": \"one\", \"text\": \"Hello.\"}\n", wantIDs: []string{"one"}},
+		{fileName: "prompts.txt", content: "Hello.\nHi.\n", wantIDs: []string{"0001", "0002"}},
+		{fileName: "empty.txt", content: "\n\n", wantErr: true},
+		{fileName: "duplicate.csv", content: "one,Hello.\none,Hi.\n", wantErr: true},
+		{fileName: "missing-text.csv", content: "one,\n", wantErr: true},
+		{fileName: "invalid-id.jsonl", content: "{\"id\": \"../one\", \"text\": \"Hello.\"}\n", wantErr: true},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		batchFilePath := filepath.Join(dir, test.fileName)
+		if err := os.WriteFile(batchFilePath, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		items, err := readBatchTTS(batchFilePath)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("readBatchTTS(%q) returned no error", test.fileName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readBatchTTS(%q) returned error %v", test.fileName, err)
+			continue
+		}
+
+		var ids []string
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if !reflect.DeepEqual(ids, test.wantIDs) {
+			t.Errorf("readBatchTTS(%q) returned ids %q, want %q", test.fileName, ids, test.wantIDs)
+		}
+	}
+}
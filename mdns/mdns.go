@@ -0,0 +1,285 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WYOMING_SERVICE is the DNS-SD service type Wyoming servers are advertised with.
+const WYOMING_SERVICE = "_wyoming._tcp"
+
+const typeA = 1
+const typePTR = 12
+const typeTXT = 16
+const typeAAAA = 28
+const typeSRV = 33
+const classIN = 1
+
+// unicastResponseBit asks responders to answer a question directly instead of by multicast.
+const unicastResponseBit = 0x8000
+
+var multicastAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Service is a DNS-SD service instance found by Browse.
+type Service struct {
+	// Instance is the name of the service instance, such as "piper".
+	Instance string
+	Host     string
+	Port     int
+	IPs      []net.IP
+	TXT      []string
+}
+
+// Addr returns the "host:port" address of s, preferring an IPv4 address.
+func (s Service) Addr() string {
+	host := strings.TrimSuffix(s.Host, ".")
+	for _, ip := range s.IPs {
+		if ip.To4() != nil {
+			return net.JoinHostPort(ip.String(), strconv.Itoa(s.Port))
+		}
+	}
+	if len(s.IPs) > 0 {
+		host = s.IPs[0].String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(s.Port))
+}
+
+// record is a resource record from a DNS message. Only the fields used by the record's type are set.
+type record struct {
+	Name   string
+	Type   int
+	Target string
+	Port   int
+	IP     net.IP
+	TXT    []string
+}
+
+// buildQuery returns a DNS query for PTR records of name.
+func buildQuery(name string) []byte {
+	// id, flags, 1 question, no answers, authority or additional records
+	message := make([]byte, 12)
+	binary.BigEndian.PutUint16(message[4:], 1)
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		message = append(message, byte(len(label)))
+		message = append(message, label...)
+	}
+	message = append(message, 0)
+	message = binary.BigEndian.AppendUint16(message, typePTR)
+	message = binary.BigEndian.AppendUint16(message, classIN|unicastResponseBit)
+
+	return message
+}
+
+// readName reads the possibly compressed name starting at offset in message and returns it with the offset after
+// the name.
+func readName(message []byte, offset int) (string, int, error) {
+	var labels []string
+	end := -1
+
+	for jumps := 0; ; {
+		if offset >= len(message) {
+			return "", 0, errors.New("name is outside of the message")
+		}
+		length := int(message[offset])
+
+		switch {
+		case length == 0:
+			if end == -1 {
+				end = offset + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(message) {
+				return "", 0, errors.New("name pointer is outside of the message")
+			}
+			if end == -1 {
+				end = offset + 2
+			}
+			jumps += 1
+			if jumps > 32 {
+				return "", 0, errors.New("name has too many pointers")
+			}
+			offset = int(binary.BigEndian.Uint16(message[offset:]) & 0x3fff)
+		default:
+			if offset+1+length > len(message) {
+				return "", 0, errors.New("label is outside of the message")
+			}
+			labels = append(labels, string(message[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// parseResponse returns the answer, authority and additional records of the DNS response message.
+func parseResponse(message []byte) ([]record, error) {
+	if len(message) < 12 {
+		return nil, errors.New("message is too short")
+	}
+	if message[2]&0x80 == 0 {
+		return nil, errors.New("message is not a response")
+	}
+
+	questionsCount := int(binary.BigEndian.Uint16(message[4:]))
+	recordsCount := int(binary.BigEndian.Uint16(message[6:])) + int(binary.BigEndian.Uint16(message[8:])) + int(binary.BigEndian.Uint16(message[10:]))
+	offset := 12
+
+	for i := 0; i < questionsCount; i += 1 {
+		_, next, err := readName(message, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4
+	}
+
+	var records []record
+	for i := 0; i < recordsCount; i += 1 {
+		name, next, err := readName(message, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(message) {
+			return nil, errors.New("record is outside of the message")
+		}
+
+		r := record{Name: name, Type: int(binary.BigEndian.Uint16(message[next:]))}
+		dataLength := int(binary.BigEndian.Uint16(message[next+8:]))
+		dataStart := next + 10
+		dataEnd := dataStart + dataLength
+		if dataEnd > len(message) {
+			return nil, errors.New("record data is outside of the message")
+		}
+		data := message[dataStart:dataEnd]
+
+		switch r.Type {
+		case typePTR:
+			r.Target, _, err = readName(message, dataStart)
+		case typeSRV:
+			if dataLength < 7 {
+				return nil, errors.New("SRV record is too short")
+			}
+			r.Port = int(binary.BigEndian.Uint16(data[4:]))
+			r.Target, _, err = readName(message, dataStart+6)
+		case typeA, typeAAAA:
+			r.IP = net.IP(append([]byte(nil), data...))
+		case typeTXT:
+			for j := 0; j < len(data); {
+				length := int(data[j])
+				if j+1+length > len(data) {
+					break
+				}
+				if length > 0 {
+					r.TXT = append(r.TXT, string(data[j+1:j+1+length]))
+				}
+				j += 1 + length
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+		offset = dataEnd
+	}
+
+	return records, nil
+}
+
+// Browse sends an mDNS query for instances of service, such as WYOMING_SERVICE, on the local network and returns
+// the instances that answered within timeout, sorted by name.
+func Browse(service string, timeout time.Duration) ([]Service, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	serviceName := strings.TrimSuffix(service, ".") + ".local."
+	if _, err := conn.WriteToUDP(buildQuery(serviceName), multicastAddr); err != nil {
+		return nil, err
+	}
+
+	var records []record
+	buf := make([]byte, 9000)
+	deadline := time.Now().Add(timeout)
+	conn.SetReadDeadline(deadline)
+	for time.Now().Before(deadline) {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return nil, err
+		}
+
+		// ignore messages that are not valid responses
+		response, err := parseResponse(buf[:n])
+		if err != nil {
+			continue
+		}
+		records = append(records, response...)
+	}
+
+	return servicesFromRecords(serviceName, records), nil
+}
+
+// servicesFromRecords combines the PTR, SRV, TXT and address records for instances of serviceName.
+func servicesFromRecords(serviceName string, records []record) []Service {
+	servicesByName := map[string]*Service{}
+	for _, r := range records {
+		if r.Type == typePTR && strings.EqualFold(r.Name, serviceName) {
+			instance := strings.TrimSuffix(r.Target, "."+serviceName)
+			servicesByName[strings.ToLower(r.Target)] = &Service{Instance: instance}
+		}
+	}
+
+	IPsByHost := map[string][]net.IP{}
+	for _, r := range records {
+		key := strings.ToLower(r.Name)
+		switch r.Type {
+		case typeSRV:
+			if service, ok := servicesByName[key]; ok {
+				service.Host = r.Target
+				service.Port = r.Port
+			}
+		case typeTXT:
+			if service, ok := servicesByName[key]; ok {
+				service.TXT = r.TXT
+			}
+		case typeA, typeAAAA:
+			IPsByHost[key] = append(IPsByHost[key], r.IP)
+		}
+	}
+
+	var services []Service
+	for _, service := range servicesByName {
+		// instances without a SRV record cannot be connected to
+		if service.Port == 0 {
+			continue
+		}
+		for _, ip := range IPsByHost[strings.ToLower(service.Host)] {
+			if !containsIP(service.IPs, ip) {
+				service.IPs = append(service.IPs, ip)
+			}
+		}
+		services = append(services, *service)
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Instance < services[j].Instance })
+	return services
+}
+
+func containsIP(IPs []net.IP, ip net.IP) bool {
+	for _, existing := range IPs {
+		if existing.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
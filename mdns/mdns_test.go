@@ -0,0 +1,221 @@
+package mdns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// messageBuilder builds DNS messages for the tests.
+type messageBuilder struct {
+	data []byte
+}
+
+// header starts a response with the given numbers of questions and records.
+func (b *messageBuilder) header(questionsCount, answersCount, additionalCount int) {
+	b.data = []byte{0, 0, 0x84, 0}
+	for _, count := range []int{questionsCount, answersCount, 0, additionalCount} {
+		b.data = binary.BigEndian.AppendUint16(b.data, uint16(count))
+	}
+}
+
+// name appends labels followed by a pointer to pointer, or by the root label if pointer is 0, and returns the offset
+// of the name.
+func (b *messageBuilder) name(pointer int, labels ...string) int {
+	offset := len(b.data)
+	for _, label := range labels {
+		b.data = append(b.data, byte(len(label)))
+		b.data = append(b.data, label...)
+	}
+	if pointer > 0 {
+		b.data = binary.BigEndian.AppendUint16(b.data, 0xc000|uint16(pointer))
+	} else {
+		b.data = append(b.data, 0)
+	}
+	return offset
+}
+
+// record appends a record whose name points to nameOffset. rdata appends the record data and returns a value that
+// record returns, such as the offset of a name in the data.
+func (b *messageBuilder) record(nameOffset, recordType int, rdata func() int) int {
+	b.name(nameOffset)
+	b.data = binary.BigEndian.AppendUint16(b.data, uint16(recordType))
+	b.data = binary.BigEndian.AppendUint16(b.data, classIN)
+	b.data = binary.BigEndian.AppendUint32(b.data, 120)
+	lengthOffset := len(b.data)
+	b.data = append(b.data, 0, 0)
+	result := rdata()
+	binary.BigEndian.PutUint16(b.data[lengthOffset:], uint16(len(b.data)-lengthOffset-2))
+	return result
+}
+
+// testResponse returns a response advertising "piper" at raspberry.local:10200, "whisper" without a SRV record and
+// an unrelated HTTP service.
+func testResponse() []byte {
+	b := &messageBuilder{}
+	b.header(2, 3, 4)
+	serviceOffset := b.name(0, "_wyoming", "_tcp", "local")
+	b.data = append(b.data, 0, typePTR, 0, classIN)
+	httpOffset := b.name(0, "_http", "_tcp", "local")
+	b.data = append(b.data, 0, typePTR, 0, classIN)
+
+	piperOffset := b.record(serviceOffset, typePTR, func() int { return b.name(serviceOffset, "piper") })
+	b.record(serviceOffset, typePTR, func() int { return b.name(serviceOffset, "whisper") })
+	b.record(httpOffset, typePTR, func() int { return b.name(piperOffset) })
+
+	hostOffset := b.record(piperOffset, typeSRV, func() int {
+		b.data = append(b.data, 0, 0, 0, 0)
+		b.data = binary.BigEndian.AppendUint16(b.data, 10200)
+		return b.name(0, "raspberry", "local")
+	})
+	b.record(piperOffset, typeTXT, func() int {
+		b.data = append(b.data, 9)
+		b.data = append(b.data, "version=1"...)
+		b.data = append(b.data, 0, 3)
+		b.data = append(b.data, "a=b"...)
+		return 0
+	})
+	b.record(hostOffset, typeAAAA, func() int {
+		b.data = append(b.data, net.ParseIP("fe80::1")...)
+		return 0
+	})
+	b.record(hostOffset, typeA, func() int {
+		b.data = append(b.data, net.IPv4(192, 168, 1, 5).To4()...)
+		return 0
+	})
+
+	return b.data
+}
+
+func TestBuildQuery(t *testing.T) {
+	want := []byte{
+		0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+		8, '_', 'w', 'y', 'o', 'm', 'i', 'n', 'g', 4, '_', 't', 'c', 'p', 5, 'l', 'o', 'c', 'a', 'l', 0,
+		0, typePTR, 0x80, classIN,
+	}
+
+	for _, name := range []string{"_wyoming._tcp.local.", "_wyoming._tcp.local"} {
+		if got := buildQuery(name); !bytes.Equal(got, want) {
+			t.Errorf("buildQuery(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReadName(t *testing.T) {
+	message := []byte{
+		3, 'f', 'o', 'o', 5, 'l', 'o', 'c', 'a', 'l', 0,
+		3, 'b', 'a', 'r', 0xc0, 0,
+		0xc0, 17,
+		0xc0,
+		5, 'a',
+	}
+
+	tests := []struct {
+		offset   int
+		wantName string
+		wantEnd  int
+		wantErr  bool
+	}{
+		{offset: 0, wantName: "foo.local.", wantEnd: 11},
+		{offset: 4, wantName: "local.", wantEnd: 11},
+		{offset: 11, wantName: "bar.foo.local.", wantEnd: 17},
+		{offset: 10, wantName: ".", wantEnd: 11},
+		// a pointer to itself
+		{offset: 17, wantErr: true},
+		{offset: 19, wantErr: true},
+		{offset: 20, wantErr: true},
+		{offset: 30, wantErr: true},
+	}
+
+	for _, test := range tests {
+		name, end, err := readName(message, test.offset)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("readName at %d returned %q and no error", test.offset, name)
+			}
+			continue
+		}
+		if err != nil || name != test.wantName || end != test.wantEnd {
+			t.Errorf("readName at %d = %q, %d, %v, want %q, %d", test.offset, name, end, err, test.wantName, test.wantEnd)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	records, err := parseResponse(testResponse())
+	if err != nil {
+		t.Fatalf("parseResponse returned error %v", err)
+	}
+
+	want := []record{
+		{Name: "_wyoming._tcp.local.", Type: typePTR, Target: "piper._wyoming._tcp.local."},
+		{Name: "_wyoming._tcp.local.", Type: typePTR, Target: "whisper._wyoming._tcp.local."},
+		{Name: "_http._tcp.local.", Type: typePTR, Target: "piper._wyoming._tcp.local."},
+		{Name: "piper._wyoming._tcp.local.", Type: typeSRV, Target: "raspberry.local.", Port: 10200},
+		{Name: "piper._wyoming._tcp.local.", Type: typeTXT, TXT: []string{"version=1", "a=b"}},
+		{Name: "raspberry.local.", Type: typeAAAA, IP: net.ParseIP("fe80::1")},
+		{Name: "raspberry.local.", Type: typeA, IP: net.IP{192, 168, 1, 5}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("parseResponse = %+v, want %+v", records, want)
+	}
+}
+
+func TestParseResponseErrors(t *testing.T) {
+	response := testResponse()
+	query := buildQuery("_wyoming._tcp.local.")
+
+	tests := []struct {
+		name    string
+		message []byte
+	}{
+		{name: "too short", message: response[:8]},
+		{name: "query", message: query},
+		{name: "truncated record", message: response[:len(response)-10]},
+		{name: "truncated data", message: response[:len(response)-2]},
+	}
+
+	for _, test := range tests {
+		if _, err := parseResponse(test.message); err == nil {
+			t.Errorf("%s: parseResponse returned no error", test.name)
+		}
+	}
+}
+
+func TestServicesFromRecords(t *testing.T) {
+	records, err := parseResponse(testResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := servicesFromRecords("_wyoming._tcp.local.", records)
+	want := []Service{{
+		Instance: "piper",
+		Host:     "raspberry.local.",
+		Port:     10200,
+		IPs:      []net.IP{net.ParseIP("fe80::1"), {192, 168, 1, 5}},
+		TXT:      []string{"version=1", "a=b"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("servicesFromRecords = %+v, want %+v", got, want)
+	}
+}
+
+func TestServiceAddr(t *testing.T) {
+	tests := []struct {
+		service Service
+		want    string
+	}{
+		{service: Service{Host: "raspberry.local.", Port: 10200, IPs: []net.IP{net.ParseIP("fe80::1"), net.IPv4(192, 168, 1, 5)}}, want: "192.168.1.5:10200"},
+		{service: Service{Host: "raspberry.local.", Port: 10200, IPs: []net.IP{net.ParseIP("fe80::1")}}, want: "[fe80::1]:10200"},
+		{service: Service{Host: "raspberry.local.", Port: 10200}, want: "raspberry.local:10200"},
+	}
+
+	for _, test := range tests {
+		if got := test.service.Addr(); got != test.want {
+			t.Errorf("Addr of %+v = %q, want %q", test.service, got, test.want)
+		}
+	}
+}
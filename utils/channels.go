@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"errors"
+	"io"
+)
+
+// SplitChannels16Bits de-interleaves the 16-bit audio read from reader into one reader per channel. Each returned
+// reader must be read until it returns an error, otherwise reading from the other channels will block. Errors
+// returned by reader, including io.EOF, are returned by every channel reader once its audio has been read. Closing
+// any of the readers with CloseWithError stops reading from reader and passes the error to the other channels.
+func SplitChannels16Bits(reader io.Reader, channels int) ([]*io.PipeReader, error) {
+	if channels <= 0 {
+		return nil, errors.New("invalid number of channels")
+	}
+
+	pipeReaders := make([]*io.PipeReader, channels)
+	pipeWriters := make([]*io.PipeWriter, channels)
+	for i := range pipeReaders {
+		pipeReader, pipeWriter := io.Pipe()
+		pipeReaders[i] = pipeReader
+		pipeWriters[i] = pipeWriter
+	}
+
+	go func() {
+		frameSize := channels * 2
+		interleaved := make([]byte, frameSize*1024)
+		channelBuffs := make([][]byte, channels)
+
+		for {
+			n, err := io.ReadFull(reader, interleaved)
+			n -= n % frameSize
+
+			for c := range channelBuffs {
+				channelBuffs[c] = channelBuffs[c][:0]
+			}
+			for offset := 0; offset < n; offset += frameSize {
+				for c := range channelBuffs {
+					channelBuffs[c] = append(channelBuffs[c], interleaved[offset+c*2:offset+c*2+2]...)
+				}
+			}
+			for c, pipeWriter := range pipeWriters {
+				if len(channelBuffs[c]) == 0 {
+					continue
+				}
+				if _, writeErr := pipeWriter.Write(channelBuffs[c]); writeErr != nil {
+					// a channel reader was closed
+					err = writeErr
+					break
+				}
+			}
+
+			if err != nil {
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					err = io.EOF
+				}
+				for _, pipeWriter := range pipeWriters {
+					pipeWriter.CloseWithError(err)
+				}
+				return
+			}
+		}
+	}()
+
+	return pipeReaders, nil
+}
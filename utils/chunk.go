@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+// ChunkSegmenter splits 16-bit PCM audio into fixed length chunks of ChunkMS without detecting sound. Each chunk
+// starts with the last OverlapMS of the previous chunk so that words cut at a chunk boundary are heard in full by
+// one of the chunks. ChunkSegmenter implements AudioSegmenter.
+type ChunkSegmenter struct {
+	Rate      int
+	Channels  int
+	ChunkMS   int
+	OverlapMS int
+
+	offsetBytes int
+	overlap     []byte
+	finished    bool
+}
+
+// bytesForMS returns the number of bytes in durationMS of audio, aligned to a full frame.
+func (c *ChunkSegmenter) bytesForMS(durationMS int) int {
+	return int(int64(c.Rate)*int64(durationMS)/1000) * c.Channels * 2
+}
+
+// durationForBytes returns the duration of byteCount bytes of audio.
+func (c *ChunkSegmenter) durationForBytes(byteCount int) time.Duration {
+	return time.Duration(float64(byteCount) / float64(c.Rate*c.Channels*2) * float64(time.Second))
+}
+
+// NextAudioGroup reads the next chunk of audio from reader. io.EOF is returned once no new audio is left.
+func (c *ChunkSegmenter) NextAudioGroup(reader io.Reader) (AudioEvent, error) {
+	if c.finished {
+		return AudioEvent{}, io.EOF
+	}
+
+	chunkBytes := c.bytesForMS(c.ChunkMS)
+	overlapBytes := c.bytesForMS(c.OverlapMS)
+	if chunkBytes <= 0 || overlapBytes < 0 || overlapBytes >= chunkBytes {
+		return AudioEvent{}, errors.New("invalid chunk size")
+	}
+
+	chunk := make([]byte, chunkBytes)
+	copied := copy(chunk, c.overlap)
+	n, err := io.ReadFull(reader, chunk[copied:])
+	if err != nil {
+		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return AudioEvent{}, err
+		}
+		c.finished = true
+		if n == 0 {
+			return AudioEvent{}, io.EOF
+		}
+	}
+	chunk = chunk[:copied+n]
+
+	startBytes := c.offsetBytes - copied
+	audioEvent := AudioEvent{
+		Start: c.durationForBytes(startBytes),
+		End:   c.durationForBytes(startBytes + len(chunk)),
+	}
+	audioEvent.SoundBuff.Write(chunk)
+
+	c.offsetBytes += n
+	c.overlap = bytes.Clone(chunk[max(len(chunk)-overlapBytes, 0):])
+
+	return audioEvent, nil
+}
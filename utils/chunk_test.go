@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestChunkSegmenter(t *testing.T) {
+	tests := []struct {
+		name      string
+		audioMS   int
+		chunkMS   int
+		overlapMS int
+		want      []testSegment
+	}{
+		{name: "overlap", audioMS: 2500, chunkMS: 1000, overlapMS: 200, want: []testSegment{{startMS: 0, endMS: 1000}, {startMS: 800, endMS: 1800}, {startMS: 1600, endMS: 2500}}},
+		{name: "no partial chunk", audioMS: 2600, chunkMS: 1000, overlapMS: 200, want: []testSegment{{startMS: 0, endMS: 1000}, {startMS: 800, endMS: 1800}, {startMS: 1600, endMS: 2600}}},
+		{name: "no overlap", audioMS: 2500, chunkMS: 1000, overlapMS: 0, want: []testSegment{{startMS: 0, endMS: 1000}, {startMS: 1000, endMS: 2000}, {startMS: 2000, endMS: 2500}}},
+		{name: "short audio", audioMS: 300, chunkMS: 1000, overlapMS: 200, want: []testSegment{{startMS: 0, endMS: 300}}},
+		{name: "no audio", audioMS: 0, chunkMS: 1000, overlapMS: 200, want: nil},
+	}
+
+	for _, test := range tests {
+		audio := make([]byte, test.audioMS*TEST_RATE/1000*2)
+		for i := range audio {
+			audio[i] = byte(i % 251)
+		}
+
+		segmenter := &ChunkSegmenter{Rate: TEST_RATE, Channels: 1, ChunkMS: test.chunkMS, OverlapMS: test.overlapMS}
+		reader := bytes.NewReader(audio)
+		var got []testSegment
+		for {
+			audioEvent, err := segmenter.NextAudioGroup(reader)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: NextAudioGroup returned error %v", test.name, err)
+			}
+
+			segment := testSegment{startMS: int(audioEvent.Start.Milliseconds()), endMS: int(audioEvent.End.Milliseconds())}
+			wantAudio := audio[segment.startMS*TEST_RATE/1000*2 : segment.endMS*TEST_RATE/1000*2]
+			if !bytes.Equal(audioEvent.SoundBuff.Bytes(), wantAudio) {
+				t.Errorf("%s: segment %+v does not hold the audio between its start and end", test.name, segment)
+			}
+			got = append(got, segment)
+		}
+
+		compareSegments(t, test.name, got, test.want)
+	}
+}
+
+func TestChunkSegmenterInvalidSize(t *testing.T) {
+	tests := []ChunkSegmenter{
+		{Rate: TEST_RATE, Channels: 1, ChunkMS: 0},
+		{Rate: TEST_RATE, Channels: 1, ChunkMS: 1000, OverlapMS: 1000},
+		{Rate: TEST_RATE, Channels: 1, ChunkMS: 1000, OverlapMS: -100},
+	}
+
+	for _, segmenter := range tests {
+		if _, err := segmenter.NextAudioGroup(bytes.NewReader(make([]byte, 64000))); err == nil {
+			t.Errorf("NextAudioGroup with %d ms chunks and %d ms overlap returned no error", segmenter.ChunkMS, segmenter.OverlapMS)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// SplitCommandLine splits a command line into its arguments like a POSIX shell. Arguments are separated by
+// whitespace, single quotes keep everything up to the next single quote, double quotes keep everything up to the
+// next double quote except for backslash escapes of '"', '\' and '$', and a backslash outside of quotes keeps the
+// next character. Variables, globs and other shell expansions are not supported.
+func SplitCommandLine(commandLine string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+
+	runes := []rune(commandLine)
+	for i := 0; i < len(runes); i += 1 {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, errors.New("unfinished escape in command")
+			}
+			i += 1
+			current.WriteRune(runes[i])
+			inArg = true
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end += 1
+			}
+			if end >= len(runes) {
+				return nil, errors.New("unterminated quote in command")
+			}
+			current.WriteString(string(runes[i+1 : end]))
+			i = end
+			inArg = true
+		case r == '"':
+			i += 1
+			for ; i < len(runes) && runes[i] != '"'; i += 1 {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$", runes[i+1]) {
+					i += 1
+				}
+				current.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated quote in command")
+			}
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		commandLine string
+		want        []string
+	}{
+		{commandLine: "piper --voice en_US-amy", want: []string{"piper", "--voice", "en_US-amy"}},
+		{commandLine: "  piper \t --voice  en_US-amy ", want: []string{"piper", "--voice", "en_US-amy"}},
+		{commandLine: "'/opt/my models/piper' --data-dir \"/var/lib/piper data\"", want: []string{"/opt/my models/piper", "--data-dir", "/var/lib/piper data"}},
+		{commandLine: `server --name it\'s`, want: []string{"server", "--name", "it's"}},
+		{commandLine: `server "a \"quoted\" \n word"`, want: []string{"server", `a "quoted" \n word`}},
+		{commandLine: `server '' "" x`, want: []string{"server", "", "", "x"}},
+		{commandLine: `server --flag=a' 'b`, want: []string{"server", "--flag=a b"}},
+		{commandLine: "", want: nil},
+	}
+
+	for _, test := range tests {
+		got, err := SplitCommandLine(test.commandLine)
+		if err != nil {
+			t.Errorf("SplitCommandLine(%q) returned error %v", test.commandLine, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitCommandLine(%q) = %q, want %q", test.commandLine, got, test.want)
+		}
+	}
+}
+
+func TestSplitCommandLineErrors(t *testing.T) {
+	tests := []string{
+		"server 'unterminated",
+		`server "unterminated`,
+		`server trailing\`,
+	}
+
+	for _, commandLine := range tests {
+		if _, err := SplitCommandLine(commandLine); err == nil {
+			t.Errorf("SplitCommandLine(%q) returned no error", commandLine)
+		}
+	}
+}
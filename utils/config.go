@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Config is a configuration file read by ReadConfig. Settings are kept as strings so they can be passed to flags.
+type Config struct {
+	// Defaults are the settings before the first section.
+	Defaults map[string]string
+	// Profiles are the settings in each "[profiles.<name>]" section.
+	Profiles map[string]map[string]string
+}
+
+// parseConfigKey returns key without quotes.
+func parseConfigKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, `"`) {
+		return strconv.Unquote(key)
+	}
+	if strings.HasPrefix(key, "'") {
+		if len(key) < 2 || !strings.HasSuffix(key, "'") {
+			return "", fmt.Errorf("invalid key %s", key)
+		}
+		return key[1 : len(key)-1], nil
+	}
+	if key == "" || strings.ContainsAny(key, " \t\"'") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return key, nil
+}
+
+// parseConfigValue returns a string, number or boolean value and the text after it.
+func parseConfigValue(value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(value, `"`):
+		// find the closing quote, skipping escaped characters
+		for i := 1; i < len(value); i += 1 {
+			if value[i] == '\\' {
+				i += 1
+				continue
+			}
+			if value[i] == '"' {
+				parsed, err := strconv.Unquote(value[:i+1])
+				return parsed, value[i+1:], err
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string %s", value)
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end == -1 {
+			return "", "", fmt.Errorf("unterminated string %s", value)
+		}
+		return value[1 : end+1], value[end+2:], nil
+	}
+
+	end := strings.IndexAny(value, " \t#")
+	if end == -1 {
+		end = len(value)
+	}
+	parsed, rest := value[:end], value[end:]
+
+	if parsed == "true" || parsed == "false" {
+		return parsed, rest, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(parsed, "_", ""), 64); err == nil {
+		return strings.ReplaceAll(parsed, "_", ""), rest, nil
+	}
+	return "", "", fmt.Errorf("invalid value %q", value)
+}
+
+// parseConfigSection returns the profile name of a "[profiles.<name>]" section header.
+func parseConfigSection(header string) (string, error) {
+	sectionName := strings.TrimSpace(header[1 : len(header)-1])
+
+	profileName, isProfile := strings.CutPrefix(sectionName, "profiles.")
+	if !isProfile {
+		return "", fmt.Errorf("unknown section [%s], expected [profiles.<name>]", sectionName)
+	}
+	return parseConfigKey(profileName)
+}
+
+// ReadConfig reads a configuration file written in a subset of TOML: "key = value" lines with string, number or
+// boolean values, "#" comments and "[profiles.<name>]" sections.
+func ReadConfig(reader io.Reader) (Config, error) {
+	config := Config{Defaults: map[string]string{}, Profiles: map[string]map[string]string{}}
+	settings := config.Defaults
+
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			header, _, _ := strings.Cut(line, "#")
+			header = strings.TrimSpace(header)
+			if !strings.HasSuffix(header, "]") || strings.HasPrefix(header, "[[") {
+				return Config{}, fmt.Errorf("line %d: invalid section header", lineNumber)
+			}
+
+			profileName, err := parseConfigSection(header)
+			if err != nil {
+				return Config{}, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			if _, exists := config.Profiles[profileName]; exists {
+				return Config{}, fmt.Errorf("line %d: profile %q is defined more than once", lineNumber, profileName)
+			}
+			settings = map[string]string{}
+			config.Profiles[profileName] = settings
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return Config{}, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+
+		parsedKey, err := parseConfigKey(key)
+		if err != nil {
+			return Config{}, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		parsedValue, rest, err := parseConfigValue(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+			return Config{}, fmt.Errorf("line %d: unexpected %q after value", lineNumber, rest)
+		}
+
+		settings[parsedKey] = parsedValue
+	}
+	if err := scanner.Err(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
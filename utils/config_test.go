@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	config, err := ReadConfig(strings.NewReader(`# defaults for every run
+asr-addr = "192.168.1.10:10300"
+tts-addr = '192.168.1.10:10200' # piper
+workers = 4
+sentence-silence = 1_000
+tls-insecure-skip-verify = false
+"quoted-key" = "a \"quoted\" value"
+
+[profiles.kitchen]
+voice-name = "en_US-amy"
+speed = 1.25
+
+[ profiles."living room" ] # with a space
+voice-name = "de_DE-thorsten"
+`))
+	if err != nil {
+		t.Fatalf("ReadConfig returned error %v", err)
+	}
+
+	want := Config{
+		Defaults: map[string]string{
+			"asr-addr":                 "192.168.1.10:10300",
+			"tts-addr":                 "192.168.1.10:10200",
+			"workers":                  "4",
+			"sentence-silence":         "1000",
+			"tls-insecure-skip-verify": "false",
+			"quoted-key":               `a "quoted" value`,
+		},
+		Profiles: map[string]map[string]string{
+			"kitchen":     {"voice-name": "en_US-amy", "speed": "1.25"},
+			"living room": {"voice-name": "de_DE-thorsten"},
+		},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("ReadConfig = %+v, want %+v", config, want)
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	tests := []struct {
+		config  string
+		wantErr string
+	}{
+		{config: "addr", wantErr: "line 1: expected key = value"},
+		{config: "# comment\naddr = localhost:10200", wantErr: "line 2: invalid value"},
+		{config: `addr = "localhost:10200`, wantErr: "line 1: unterminated string"},
+		{config: "addr = 'localhost:10200", wantErr: "line 1: unterminated string"},
+		{config: `addr = "localhost:10200" extra`, wantErr: "line 1: unexpected"},
+		{config: "my key = 1", wantErr: "line 1: invalid key"},
+		{config: " = 1", wantErr: "line 1: invalid key"},
+		{config: "[servers]", wantErr: "line 1: unknown section [servers]"},
+		{config: "[profiles.a", wantErr: "line 1: invalid section header"},
+		{config: "[[profiles.a]]", wantErr: "line 1: invalid section header"},
+		{config: "[profiles.a]\n[profiles.a]", wantErr: `line 2: profile "a" is defined more than once`},
+	}
+
+	for _, test := range tests {
+		_, err := ReadConfig(strings.NewReader(test.config))
+		if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+			t.Errorf("ReadConfig(%q) returned error %v, want %q", test.config, err, test.wantErr)
+		}
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"math"
 	"os"
 )
 
+// STREAMING_WAV_DATA_LENGTH is the largest data length a WAV header can hold. It is used for audio that is
+// streamed before its length is known.
+const STREAMING_WAV_DATA_LENGTH = math.MaxInt32 - 36
+
 // ConvertPCMAudioFileToWAVFile converts the PCM audio file located at PCMFilePath to a new WAV file located at WAVFilePath.
 func ConvertPCMAudioFileToWAVFile(WAVFilePath, PCMFilePath string, rate int32, channels int16, bitsPerSample int16) error {
 	_, err := os.Stat(WAVFilePath)
@@ -44,7 +49,23 @@ func ConvertPCMAudioFileToWAVFile(WAVFilePath, PCMFilePath string, rate int32, c
 
 // ConvertPCMAudioToWAV writes necessary WAV file header data and PCM audio data from PCMReader to WAVWriter.
 func ConvertPCMAudioToWAV(WAVWriter io.Writer, PCMReader io.Reader, PCMDataLength int, rate int32, channels int16, bitsPerSample int16) error {
-	// write WAV header
+	err := WriteWAVHeader(WAVWriter, PCMDataLength, rate, channels, bitsPerSample)
+	if err != nil {
+		return err
+	}
+
+	// write audio data
+	_, err = io.Copy(WAVWriter, PCMReader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WriteWAVHeader writes the WAV file header for PCMDataLength bytes of PCM audio to WAVWriter. Use
+// STREAMING_WAV_DATA_LENGTH when the length is not known ahead of time.
+func WriteWAVHeader(WAVWriter io.Writer, PCMDataLength int, rate int32, channels int16, bitsPerSample int16) error {
 	var blockAlign int16 = channels * (bitsPerSample / 8)
 	var byteRate int32 = rate * int32(blockAlign)
 
@@ -76,12 +97,6 @@ func ConvertPCMAudioToWAV(WAVWriter io.Writer, PCMReader io.Reader, PCMDataLengt
 		}
 	}
 
-	// write audio data
-	_, err := io.Copy(WAVWriter, PCMReader)
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -16,6 +16,10 @@ type AudioEvent struct {
 	Start     time.Duration
 	End       time.Duration
 	SoundBuff bytes.Buffer
+	// Split is true when the segment was cut at a maximum length and continues in the next segment.
+	Split bool
+	// Continued is true when the segment continues a previous segment that was split.
+	Continued bool
 }
 
 // DetectAudioEvent16Bits reads from reader and detects if an audio event occurred during one audioWindowMS duration. An event is
@@ -64,6 +68,13 @@ func DetectAudioEvent16Bits(reader io.Reader, mode, rate, channels, audioWindowM
 // returns the offset of the start of the event in MS and a buffer containing the audio of the event. If detectMode is set to DETECT_SILENCE_MODE and an EOF error
 // is returned while reading from reader then durationMS is ignored and DetectAudioEventDuration16Bits returns a silence event.
 func DetectAudioEventDuration16Bits(reader io.Reader, rate, channels, durationMS, audioWindowMS, detectMode int, audioThreshold int32) (int, bytes.Buffer, error) {
+	return detectAudioEventDuration(reader, durationMS, audioWindowMS, detectMode, func(r io.Reader) (bool, error) {
+		return DetectAudioEvent16Bits(r, detectMode, rate, channels, audioWindowMS, audioThreshold)
+	})
+}
+
+// detectAudioEventDuration calls detect once per audioWindowMS window until it reports durationMS worth of consecutive events.
+func detectAudioEventDuration(reader io.Reader, durationMS, audioWindowMS, detectMode int, detect func(io.Reader) (bool, error)) (int, bytes.Buffer, error) {
 	events := 0
 	minEvents := durationMS / audioWindowMS
 
@@ -73,7 +84,7 @@ func DetectAudioEventDuration16Bits(reader io.Reader, rate, channels, durationMS
 	currentOffsetMS := 0
 
 	for {
-		eventDetected, err := DetectAudioEvent16Bits(teeReader, detectMode, rate, channels, audioWindowMS, audioThreshold)
+		eventDetected, err := detect(teeReader)
 		if err != nil {
 			if errors.Is(err, io.EOF) && detectMode == DETECT_SILENCE_MODE {
 				if events == 0 {
@@ -109,7 +120,18 @@ func DetectAudioEventDuration16Bits(reader io.Reader, rate, channels, durationMS
 // DetectNextAudioGroup16Bit reads from reader and detects the next segment of audio. The AudioEvent returned contains the start
 // and end time offset by offsetMS and a buffer containing the audio data.
 func DetectNextAudioGroup16Bit(reader io.Reader, rate, channels, audioWindowMS, offsetMS int, soundThreshold, silenceThreshold int32, soundDurationMS, silenceDurationMS int) (AudioEvent, error) {
-	soundOffsetMS, soundBuff, err := DetectAudioEventDuration16Bits(reader, rate, channels, soundDurationMS, audioWindowMS, DETECT_NOISE_MODE, soundThreshold)
+	return detectNextAudioGroup(reader, audioWindowMS, offsetMS, soundDurationMS, silenceDurationMS, func(r io.Reader, durationMS, detectMode int) (int, bytes.Buffer, error) {
+		audioThreshold := soundThreshold
+		if detectMode == DETECT_SILENCE_MODE {
+			audioThreshold = silenceThreshold
+		}
+		return DetectAudioEventDuration16Bits(r, rate, channels, durationMS, audioWindowMS, detectMode, audioThreshold)
+	})
+}
+
+// detectNextAudioGroup uses detectDuration to find a sound event followed by a silence event and returns the audio in between.
+func detectNextAudioGroup(reader io.Reader, audioWindowMS, offsetMS, soundDurationMS, silenceDurationMS int, detectDuration func(r io.Reader, durationMS, detectMode int) (int, bytes.Buffer, error)) (AudioEvent, error) {
+	soundOffsetMS, soundBuff, err := detectDuration(reader, soundDurationMS, DETECT_NOISE_MODE)
 	if err != nil {
 		return AudioEvent{}, err
 	}
@@ -117,7 +139,7 @@ func DetectNextAudioGroup16Bit(reader io.Reader, rate, channels, audioWindowMS,
 	startTimeMS := soundOffsetMS + offsetMS
 
 	teeReader := io.TeeReader(reader, &soundBuff)
-	silenceOffsetMS, silenceBuff, err := DetectAudioEventDuration16Bits(teeReader, rate, channels, silenceDurationMS, audioWindowMS, DETECT_SILENCE_MODE, silenceThreshold)
+	silenceOffsetMS, silenceBuff, err := detectDuration(teeReader, silenceDurationMS, DETECT_SILENCE_MODE)
 	if err != nil {
 		return AudioEvent{}, err
 	}
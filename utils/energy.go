@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"math"
+)
+
+// MIN_DBFS is the lowest level reported for 16-bit audio. Digital silence is clamped to this value.
+const MIN_DBFS float64 = -96
+
+// EnergyDetector detects audio events using the RMS energy of each window in dBFS compared against an adaptive
+// estimate of the background noise floor. Unlike DetectAudioEvent16Bits, short clicks and pops carry very little
+// energy over a full window and are not mistaken for sound. EnergyDetector implements VoiceActivityDetector.
+type EnergyDetector struct {
+	// SoundMarginDB is how far above the noise floor a window must be to count as sound.
+	SoundMarginDB float64
+	// SilenceMarginDB is how far above the noise floor a window may be and still count as silence.
+	SilenceMarginDB float64
+	// FallRate and RiseRate control how quickly the noise floor follows quieter and louder windows.
+	FallRate float64
+	RiseRate float64
+
+	NoiseFloorDB float64
+	initialized  bool
+}
+
+// NewEnergyDetector returns an EnergyDetector using soundMarginDB and silenceMarginDB with default adaptation rates.
+func NewEnergyDetector(soundMarginDB, silenceMarginDB float64) *EnergyDetector {
+	return &EnergyDetector{
+		SoundMarginDB:   soundMarginDB,
+		SilenceMarginDB: silenceMarginDB,
+		FallRate:        0.5,
+		RiseRate:        0.02,
+	}
+}
+
+// RMSDBFS16Bits returns the RMS level of samples in dBFS.
+func RMSDBFS16Bits(samples []int16) float64 {
+	if len(samples) == 0 {
+		return MIN_DBFS
+	}
+
+	var sum float64
+	for _, sample := range samples {
+		sum += float64(sample) * float64(sample)
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+	if rms == 0 {
+		return MIN_DBFS
+	}
+
+	return math.Max(20*math.Log10(rms/math.MaxInt16), MIN_DBFS)
+}
+
+// updateNoiseFloor moves the noise floor estimate towards levelDB. Windows that look like sound only raise the
+// estimate while it is still being initialized.
+func (e *EnergyDetector) updateNoiseFloor(levelDB float64) {
+	if !e.initialized {
+		e.NoiseFloorDB = levelDB
+		e.initialized = true
+		return
+	}
+
+	if levelDB < e.NoiseFloorDB {
+		e.NoiseFloorDB += (levelDB - e.NoiseFloorDB) * e.FallRate
+	} else if levelDB < e.NoiseFloorDB+e.SoundMarginDB {
+		e.NoiseFloorDB += (levelDB - e.NoiseFloorDB) * e.RiseRate
+	}
+}
+
+// ProcessFrame implements VoiceActivityDetector. A window is sound if its level is more than SoundMarginDB above
+// the noise floor and silence if it is less than SilenceMarginDB above it.
+func (e *EnergyDetector) ProcessFrame(frame []int16, rate, channels int) VADResult {
+	levelDB := RMSDBFS16Bits(frame)
+	e.updateNoiseFloor(levelDB)
+
+	return VADResult{
+		Probability: math.Min(math.Max((levelDB-e.NoiseFloorDB)/e.SoundMarginDB, 0), 1),
+		Sound:       levelDB > e.NoiseFloorDB+e.SoundMarginDB,
+		Silence:     levelDB < e.NoiseFloorDB+e.SilenceMarginDB,
+	}
+}
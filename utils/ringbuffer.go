@@ -0,0 +1,76 @@
+package utils
+
+// RingBuffer keeps the most recent bytes written to it up to a fixed capacity. Positions are absolute offsets
+// counted from the first byte ever written.
+type RingBuffer struct {
+	data   []byte
+	start  int
+	length int
+	total  int64
+}
+
+// NewRingBuffer returns a RingBuffer holding up to capacity bytes.
+func NewRingBuffer(capacity int) *RingBuffer {
+	return &RingBuffer{data: make([]byte, capacity)}
+}
+
+// Write implements io.Writer. Older bytes are overwritten once the buffer is full.
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	r.total += int64(n)
+
+	capacity := len(r.data)
+	if capacity == 0 {
+		return n, nil
+	}
+	if len(p) > capacity {
+		p = p[len(p)-capacity:]
+	}
+
+	for len(p) > 0 {
+		end := (r.start + r.length) % capacity
+		copied := copy(r.data[end:min(capacity, end+capacity-r.length)], p)
+		if copied == 0 {
+			// buffer is full, drop the oldest bytes
+			dropped := min(len(p), capacity)
+			r.start = (r.start + dropped) % capacity
+			r.length -= dropped
+			continue
+		}
+		r.length += copied
+		p = p[copied:]
+	}
+
+	return n, nil
+}
+
+// Total returns the number of bytes written to the buffer.
+func (r *RingBuffer) Total() int64 {
+	return r.total
+}
+
+// Oldest returns the position of the oldest byte still held by the buffer.
+func (r *RingBuffer) Oldest() int64 {
+	return r.total - int64(r.length)
+}
+
+// Slice returns a copy of the bytes between the positions from and to. The range is clamped to the bytes still
+// held by the buffer.
+func (r *RingBuffer) Slice(from, to int64) []byte {
+	from = max(from, r.Oldest())
+	to = min(to, r.total)
+	if from >= to {
+		return nil
+	}
+
+	result := make([]byte, 0, to-from)
+	capacity := len(r.data)
+	for position := from; position < to; {
+		index := (r.start + int(position-r.Oldest())) % capacity
+		chunk := r.data[index:min(capacity, index+int(to-position))]
+		result = append(result, chunk...)
+		position += int64(len(chunk))
+	}
+
+	return result
+}
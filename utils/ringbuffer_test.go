@@ -0,0 +1,47 @@
+package utils
+
+import "testing"
+
+func TestRingBuffer(t *testing.T) {
+	tests := []struct {
+		capacity   int
+		writes     []string
+		from       int64
+		to         int64
+		want       string
+		wantOldest int64
+	}{
+		{capacity: 4, writes: []string{"ab"}, from: 0, to: 2, want: "ab", wantOldest: 0},
+		{capacity: 4, writes: []string{"ab", "cdef"}, from: 0, to: 6, want: "cdef", wantOldest: 2},
+		{capacity: 4, writes: []string{"ab", "cdef"}, from: 3, to: 5, want: "de", wantOldest: 2},
+		{capacity: 4, writes: []string{"ab", "cdef"}, from: 5, to: 10, want: "f", wantOldest: 2},
+		{capacity: 4, writes: []string{"ab", "cdef"}, from: 4, to: 4, want: "", wantOldest: 2},
+		{capacity: 4, writes: []string{"abcdefghij"}, from: 0, to: 10, want: "ghij", wantOldest: 6},
+		{capacity: 5, writes: []string{"abc", "de", "fg"}, from: 2, to: 7, want: "cdefg", wantOldest: 2},
+		{capacity: 5, writes: []string{"abc", "de", "fg"}, from: 4, to: 6, want: "ef", wantOldest: 2},
+		{capacity: 0, writes: []string{"abc"}, from: 0, to: 3, want: "", wantOldest: 3},
+	}
+
+	for _, test := range tests {
+		buffer := NewRingBuffer(test.capacity)
+		var total int64
+		for _, write := range test.writes {
+			n, err := buffer.Write([]byte(write))
+			if err != nil || n != len(write) {
+				t.Errorf("Write(%q) = %d, %v", write, n, err)
+			}
+			total += int64(len(write))
+		}
+
+		if buffer.Total() != total {
+			t.Errorf("%d byte buffer after %q: Total() = %d, want %d", test.capacity, test.writes, buffer.Total(), total)
+		}
+		if buffer.Oldest() != test.wantOldest {
+			t.Errorf("%d byte buffer after %q: Oldest() = %d, want %d", test.capacity, test.writes, buffer.Oldest(), test.wantOldest)
+		}
+		got := buffer.Slice(test.from, test.to)
+		if string(got) != test.want {
+			t.Errorf("%d byte buffer after %q: Slice(%d, %d) = %q, want %q", test.capacity, test.writes, test.from, test.to, got, test.want)
+		}
+	}
+}
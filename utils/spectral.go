@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// SpectralDetector detects speech using the energy of each window along with its zero-crossing rate and spectral
+// flatness. Voiced speech has a harmonic spectrum and crosses zero relatively rarely while hiss, fans and other
+// broadband noise have a flat spectrum and a high zero-crossing rate, so loud noise is not mistaken for sound.
+// SpectralDetector implements VoiceActivityDetector.
+type SpectralDetector struct {
+	// Energy provides the noise floor estimate used to ignore windows that are too quiet to contain speech.
+	Energy *EnergyDetector
+	// MaxFlatness is the highest spectral flatness (0 for a pure tone, 1 for white noise) that can be sound.
+	MaxFlatness float64
+	// MaxZeroCrossingRate is the highest fraction of samples that may change sign in a window that is sound.
+	MaxZeroCrossingRate float64
+}
+
+// NewSpectralDetector returns a SpectralDetector using soundMarginDB and silenceMarginDB for the energy gate and
+// maxFlatness for the spectral check.
+func NewSpectralDetector(soundMarginDB, silenceMarginDB, maxFlatness float64) *SpectralDetector {
+	return &SpectralDetector{
+		Energy:              NewEnergyDetector(soundMarginDB, silenceMarginDB),
+		MaxFlatness:         maxFlatness,
+		MaxZeroCrossingRate: 0.5,
+	}
+}
+
+// mixToMono16Bits averages the channels of interleaved samples.
+func mixToMono16Bits(samples []int16, channels int) []float64 {
+	if channels <= 0 {
+		channels = 1
+	}
+
+	mono := make([]float64, len(samples)/channels)
+	for i := range mono {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			sum += float64(samples[i*channels+c])
+		}
+		mono[i] = sum / float64(channels)
+	}
+
+	return mono
+}
+
+// ZeroCrossingRate returns the fraction of adjacent sample pairs in samples that change sign.
+func ZeroCrossingRate(samples []float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	crossings := 0
+	for i := 1; i < len(samples); i += 1 {
+		if (samples[i-1] >= 0) != (samples[i] >= 0) {
+			crossings += 1
+		}
+	}
+
+	return float64(crossings) / float64(len(samples)-1)
+}
+
+// SpectralFlatness returns the ratio of the geometric mean to the arithmetic mean of the power spectrum of samples.
+func SpectralFlatness(samples []float64) float64 {
+	spectrum := fft(samples)
+
+	// only the first half of the spectrum is unique for real input, skip the DC component
+	bins := spectrum[1 : len(spectrum)/2]
+	if len(bins) == 0 {
+		return 1
+	}
+
+	var logSum, sum float64
+	for _, bin := range bins {
+		power := real(bin)*real(bin) + imag(bin)*imag(bin) + 1e-12
+		logSum += math.Log(power)
+		sum += power
+	}
+
+	geometricMean := math.Exp(logSum / float64(len(bins)))
+	arithmeticMean := sum / float64(len(bins))
+
+	return geometricMean / arithmeticMean
+}
+
+// fft returns the discrete Fourier transform of samples, zero padded to the next power of two.
+func fft(samples []float64) []complex128 {
+	n := 1
+	for n < len(samples) {
+		n <<= 1
+	}
+
+	values := make([]complex128, n)
+	for i, sample := range samples {
+		values[i] = complex(sample, 0)
+	}
+
+	// bit reversal permutation
+	for i, j := 1, 0; i < n; i += 1 {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k += 1 {
+				even := values[start+k]
+				odd := values[start+k+size/2] * w
+				values[start+k] = even + odd
+				values[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+
+	return values
+}
+
+// ProcessFrame implements VoiceActivityDetector. A window is sound if the energy detector reports sound and the
+// window does not look like broadband noise. A window is silence if the energy detector reports silence or if it
+// looks like broadband noise.
+func (s *SpectralDetector) ProcessFrame(frame []int16, rate, channels int) VADResult {
+	energyResult := s.Energy.ProcessFrame(frame, rate, channels)
+
+	mono := mixToMono16Bits(frame, channels)
+	flatness := SpectralFlatness(mono)
+	zeroCrossingRate := ZeroCrossingRate(mono)
+	noiseLike := flatness > s.MaxFlatness || zeroCrossingRate > s.MaxZeroCrossingRate
+
+	return VADResult{
+		Probability: energyResult.Probability * (1 - flatness),
+		Sound:       energyResult.Sound && !noiseLike,
+		Silence:     energyResult.Silence || noiseLike,
+	}
+}
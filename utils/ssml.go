@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SSMLSegment is a run of text spoken with one voice, preceded by BreakBeforeMS of silence. A segment without
+// text only adds silence.
+type SSMLSegment struct {
+	Text          string
+	Voice         string
+	Language      string
+	BreakBeforeMS int
+}
+
+// breakStrengths maps the "strength" attribute of <break> to a length in MS.
+var breakStrengths = map[string]int{
+	"none":     0,
+	"x-weak":   100,
+	"weak":     250,
+	"medium":   400,
+	"strong":   750,
+	"x-strong": 1200,
+}
+
+// parseBreakTime parses SSML times such as "500ms" or "1.5s".
+func parseBreakTime(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	unitMS := 1.0
+	if strings.HasSuffix(value, "ms") {
+		value = strings.TrimSuffix(value, "ms")
+	} else if strings.HasSuffix(value, "s") {
+		value = strings.TrimSuffix(value, "s")
+		unitMS = 1000
+	} else {
+		return 0, fmt.Errorf("invalid break time %q", value)
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid break time %q", value)
+	}
+
+	return int(number * unitMS), nil
+}
+
+// sayAs renders text the way interpretAs asks for it to be spoken. "characters" and "digits" are spoken one
+// character at a time. Other values are spoken as normal text.
+func sayAs(text, interpretAs string) string {
+	switch interpretAs {
+	case "characters", "spell-out", "digits":
+		var characters []string
+		for _, r := range text {
+			if unicode.IsSpace(r) {
+				continue
+			}
+			if interpretAs == "digits" && !unicode.IsDigit(r) {
+				continue
+			}
+			characters = append(characters, string(r))
+		}
+		return strings.Join(characters, " ")
+	}
+
+	return text
+}
+
+func attribute(element xml.StartElement, name string) (string, bool) {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// ParseSSML parses the subset of SSML made of <speak>, <break>, <s>, <p>, <voice> and <say-as> into segments.
+// Other elements are read as their text. sentenceBreakMS of silence is added after each <s> and paragraphBreakMS
+// after each <p>. Breaks next to each other are merged into the longest one.
+func ParseSSML(ssml string, sentenceBreakMS, paragraphBreakMS int) ([]SSMLSegment, error) {
+	type voiceState struct {
+		Name     string
+		Language string
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(ssml))
+	decoder.Strict = true
+
+	var segments []SSMLSegment
+	var text strings.Builder
+	voices := []voiceState{{}}
+	var sayAsStack []string
+	breakMS := 0
+	explicitBreak := false
+	depth := 0
+	sawSpeak := false
+
+	flush := func() {
+		segmentText := strings.Join(strings.Fields(text.String()), " ")
+		text.Reset()
+		if segmentText == "" {
+			return
+		}
+
+		voice := voices[len(voices)-1]
+		segments = append(segments, SSMLSegment{Text: segmentText, Voice: voice.Name, Language: voice.Language, BreakBeforeMS: breakMS})
+		breakMS = 0
+		explicitBreak = false
+	}
+	addBreak := func(durationMS int, explicit bool) {
+		flush()
+		breakMS = max(breakMS, durationMS)
+		explicitBreak = explicitBreak || explicit
+	}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if t.Name.Local != "speak" {
+					return nil, errors.New("SSML must start with <speak>")
+				}
+				sawSpeak = true
+			}
+			depth += 1
+
+			switch t.Name.Local {
+			case "break":
+				durationMS := breakStrengths["medium"]
+				if value, ok := attribute(t, "time"); ok {
+					durationMS, err = parseBreakTime(value)
+					if err != nil {
+						return nil, err
+					}
+				} else if value, ok := attribute(t, "strength"); ok {
+					durationMS, ok = breakStrengths[value]
+					if !ok {
+						return nil, fmt.Errorf("invalid break strength %q", value)
+					}
+				}
+				addBreak(durationMS, true)
+			case "s", "p":
+				flush()
+			case "voice":
+				flush()
+				voice := voices[len(voices)-1]
+				if name, ok := attribute(t, "name"); ok {
+					voice.Name = name
+				}
+				if language, ok := attribute(t, "lang"); ok {
+					voice.Language = language
+				}
+				voices = append(voices, voice)
+			case "say-as":
+				interpretAs, _ := attribute(t, "interpret-as")
+				sayAsStack = append(sayAsStack, interpretAs)
+			}
+		case xml.EndElement:
+			depth -= 1
+
+			switch t.Name.Local {
+			case "s":
+				addBreak(sentenceBreakMS, false)
+			case "p":
+				addBreak(paragraphBreakMS, false)
+			case "voice":
+				flush()
+				voices = voices[:len(voices)-1]
+			case "say-as":
+				sayAsStack = sayAsStack[:len(sayAsStack)-1]
+			}
+		case xml.CharData:
+			if depth == 0 {
+				if strings.TrimSpace(string(t)) != "" {
+					return nil, errors.New("SSML must start with <speak>")
+				}
+				continue
+			}
+
+			if len(sayAsStack) > 0 {
+				text.WriteString(sayAs(string(t), sayAsStack[len(sayAsStack)-1]))
+			} else {
+				text.Write(t)
+			}
+		}
+	}
+	flush()
+
+	if !sawSpeak {
+		return nil, errors.New("SSML must start with <speak>")
+	}
+
+	// only a <break> at the end adds silence, the ends of sentences and paragraphs do not
+	if explicitBreak && breakMS > 0 {
+		segments = append(segments, SSMLSegment{BreakBeforeMS: breakMS})
+	}
+
+	return segments, nil
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBreakTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "500ms", want: 500},
+		{value: "1.5s", want: 1500},
+		{value: " 2s ", want: 2000},
+		{value: "0ms", want: 0},
+		{value: "5", wantErr: true},
+		{value: "-1s", wantErr: true},
+		{value: "fastms", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseBreakTime(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("parseBreakTime(%q) = %d, %v, want %d and error %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestSayAs(t *testing.T) {
+	tests := []struct {
+		text        string
+		interpretAs string
+		want        string
+	}{
+		{text: "abc", interpretAs: "characters", want: "a b c"},
+		{text: "NASA", interpretAs: "spell-out", want: "N A S A"},
+		{text: "555-12 34", interpretAs: "digits", want: "5 5 5 1 2 3 4"},
+		{text: "12 June", interpretAs: "date", want: "12 June"},
+	}
+
+	for _, test := range tests {
+		got := sayAs(test.text, test.interpretAs)
+		if got != test.want {
+			t.Errorf("sayAs(%q, %q) = %q, want %q", test.text, test.interpretAs, got, test.want)
+		}
+	}
+}
+
+func TestParseSSML(t *testing.T) {
+	tests := []struct {
+		ssml string
+		want []SSMLSegment
+	}{
+		{
+			ssml: `<speak>Hello <break time="500ms"/> world.</speak>`,
+			want: []SSMLSegment{{Text: "Hello"}, {Text: "world.", BreakBeforeMS: 500}},
+		},
+		{
+			ssml: "<speak>\n  <p><s>One.</s><s>Two.</s></p>\n  <p>Three.</p>\n</speak>",
+			want: []SSMLSegment{{Text: "One."}, {Text: "Two.", BreakBeforeMS: 100}, {Text: "Three.", BreakBeforeMS: 300}},
+		},
+		{
+			ssml: `<speak>Hi <voice name="de_DE-thorsten" xml:lang="de-DE">Hallo</voice> bye</speak>`,
+			want: []SSMLSegment{{Text: "Hi"}, {Text: "Hallo", Voice: "de_DE-thorsten", Language: "de-DE"}, {Text: "bye"}},
+		},
+		{
+			ssml: `<speak>Call <say-as interpret-as="digits">555-12</say-as> or <say-as interpret-as="characters">abc</say-as>.</speak>`,
+			want: []SSMLSegment{{Text: "Call 5 5 5 1 2 or a b c."}},
+		},
+		{
+			ssml: `<speak>a<break/>b</speak>`,
+			want: []SSMLSegment{{Text: "a"}, {Text: "b", BreakBeforeMS: 400}},
+		},
+		{
+			// breaks next to each other are merged and a break at the end adds silence
+			ssml: `<speak><break strength="weak"/>Hi<break strength="strong"/><break time="1s"/></speak>`,
+			want: []SSMLSegment{{Text: "Hi", BreakBeforeMS: 250}, {BreakBeforeMS: 1000}},
+		},
+		{
+			ssml: `<speak>Some <emphasis>other</emphasis> element.</speak>`,
+			want: []SSMLSegment{{Text: "Some other element."}},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := ParseSSML(test.ssml, 100, 300)
+		if err != nil {
+			t.Errorf("ParseSSML(%q) returned error %v", test.ssml, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseSSML(%q) = %+v, want %+v", test.ssml, got, test.want)
+		}
+	}
+}
+
+func TestParseSSMLErrors(t *testing.T) {
+	tests := []string{
+		"Hello",
+		"<p>Hello</p>",
+		"Hello <speak>there</speak>",
+		"<speak>unclosed",
+		`<speak><break time="fast"/></speak>`,
+		`<speak><break strength="loud"/></speak>`,
+	}
+
+	for _, ssml := range tests {
+		if _, err := ParseSSML(ssml, 100, 300); err == nil {
+			t.Errorf("ParseSSML(%q) returned no error", ssml)
+		}
+	}
+}
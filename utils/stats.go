@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+// Percentile returns the pth percentile (0 to 100) of values using the nearest rank method. Percentile returns 0
+// if values is empty. values is not modified.
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+// Mean returns the average of values or 0 if values is empty.
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SubtitleCue is a piece of text shown between Start and End.
+type SubtitleCue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// formatCueTime formats duration as "HH:MM:SS" followed by decimalSeparator and milliseconds.
+func formatCueTime(duration time.Duration, decimalSeparator string) string {
+	milliseconds := max(duration.Milliseconds(), 0)
+	return fmt.Sprintf(
+		"%02d:%02d:%02d%s%03d",
+		milliseconds/3600000,
+		milliseconds/60000%60,
+		milliseconds/1000%60,
+		decimalSeparator,
+		milliseconds%1000,
+	)
+}
+
+// FormatSRT returns cues in the SubRip (.srt) subtitle format.
+func FormatSRT(cues []SubtitleCue) string {
+	var builder strings.Builder
+	for i, cue := range cues {
+		fmt.Fprintf(&builder, "%d\n%s --> %s\n%s\n\n", i+1, formatCueTime(cue.Start, ","), formatCueTime(cue.End, ","), strings.TrimSpace(cue.Text))
+	}
+	return builder.String()
+}
+
+// FormatVTT returns cues in the WebVTT (.vtt) subtitle format.
+func FormatVTT(cues []SubtitleCue) string {
+	var builder strings.Builder
+	builder.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&builder, "%s --> %s\n%s\n\n", formatCueTime(cue.Start, "."), formatCueTime(cue.End, "."), strings.TrimSpace(cue.Text))
+	}
+	return builder.String()
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// EditDistance returns the number of single character insertions, deletions and substitutions needed to change
+// a into b.
+func EditDistance(a, b string) int {
+	first, second := []rune(a), []rune(b)
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(first); i += 1 {
+		current[0] = i
+		for j := 1; j <= len(second); j += 1 {
+			substitutionCost := 1
+			if first[i-1] == second[j-1] {
+				substitutionCost = 0
+			}
+			current[j] = min(previous[j-1]+substitutionCost, previous[j]+1, current[j-1]+1)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(second)]
+}
+
+// SuggestNames returns the candidates that look like a misspelling of name, closest first. Candidates match if
+// they differ only in case, contain name, or are within a third of name's length in edits without replacing
+// all of name.
+func SuggestNames(name string, candidates []string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+
+	lowerName := strings.ToLower(name)
+	maxDistance := max(len([]rune(name))/3, 1)
+
+	var suggestions []suggestion
+	for _, candidate := range candidates {
+		lowerCandidate := strings.ToLower(candidate)
+		distance := EditDistance(lowerName, lowerCandidate)
+		if (distance <= maxDistance && distance < len([]rune(name))) || (lowerName != "" && strings.Contains(lowerCandidate, lowerName)) {
+			suggestions = append(suggestions, suggestion{name: candidate, distance: distance})
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].distance < suggestions[j].distance })
+
+	names := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		names[i] = s.name
+	}
+	return names
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const ALIGN_CORRECT byte = 'C'
+const ALIGN_SUBSTITUTION byte = 'S'
+const ALIGN_INSERTION byte = 'I'
+const ALIGN_DELETION byte = 'D'
+
+// AlignmentStep is one step of an alignment between a reference and a hypothesis. Ref is empty for insertions
+// and Hyp is empty for deletions.
+type AlignmentStep struct {
+	Op  byte
+	Ref string
+	Hyp string
+}
+
+// ErrorCounts holds the result of aligning a hypothesis to a reference.
+type ErrorCounts struct {
+	Substitutions int
+	Insertions    int
+	Deletions     int
+	// Reference is the number of tokens in the reference.
+	Reference int
+}
+
+// Add adds the counts in other to e.
+func (e *ErrorCounts) Add(other ErrorCounts) {
+	e.Substitutions += other.Substitutions
+	e.Insertions += other.Insertions
+	e.Deletions += other.Deletions
+	e.Reference += other.Reference
+}
+
+// ErrorRate returns the number of errors divided by the number of reference tokens.
+func (e ErrorCounts) ErrorRate() float64 {
+	errorCount := e.Substitutions + e.Insertions + e.Deletions
+	if e.Reference == 0 {
+		if errorCount == 0 {
+			return 0
+		}
+		return 1
+	}
+
+	return float64(errorCount) / float64(e.Reference)
+}
+
+var onesWords = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten",
+	"eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+var tensWords = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+var scaleWords = []string{"", "thousand", "million", "billion", "trillion"}
+
+// numberToWords spells out a non-negative integer in English.
+func numberToWords(number uint64) []string {
+	if number < 20 {
+		return []string{onesWords[number]}
+	}
+
+	var words []string
+	for scale := len(scaleWords) - 1; scale >= 0; scale -= 1 {
+		divisor := uint64(1)
+		for i := 0; i < scale; i += 1 {
+			divisor *= 1000
+		}
+
+		group := number / divisor
+		if group == 0 {
+			continue
+		}
+		number %= divisor
+
+		if group >= 100 {
+			words = append(words, onesWords[group/100], "hundred")
+			group %= 100
+		}
+		if group >= 20 {
+			words = append(words, tensWords[group/10])
+			group %= 10
+			if group > 0 {
+				words = append(words, onesWords[group])
+			}
+		} else if group > 0 {
+			words = append(words, onesWords[group])
+		}
+
+		if scaleWords[scale] != "" {
+			words = append(words, scaleWords[scale])
+		}
+	}
+
+	return words
+}
+
+// spellNumber spells out a number such as "1,234" or "3.5". Numbers that are too large are spelled digit by digit.
+// Any "." after the decimal point is spelled as "point".
+func spellNumber(number string) []string {
+	number = strings.ReplaceAll(number, ",", "")
+	integerPart, fractionPart, hasFraction := strings.Cut(number, ".")
+
+	var words []string
+	value, err := strconv.ParseUint(integerPart, 10, 64)
+	if err == nil && value < 1e15 {
+		words = numberToWords(value)
+	} else {
+		for i := 0; i < len(integerPart); i += 1 {
+			if integerPart[i] < '0' || integerPart[i] > '9' {
+				words = append(words, string(integerPart[i]))
+				continue
+			}
+			words = append(words, onesWords[integerPart[i]-'0'])
+		}
+	}
+
+	if hasFraction && fractionPart != "" {
+		words = append(words, "point")
+		for i := 0; i < len(fractionPart); i += 1 {
+			switch character := fractionPart[i]; {
+			case character >= '0' && character <= '9':
+				words = append(words, onesWords[character-'0'])
+			case character == '.':
+				// "1.2.3" is read as "one point two point three"
+				words = append(words, "point")
+			default:
+				words = append(words, string(character))
+			}
+		}
+	}
+
+	return words
+}
+
+// NormalizeText prepares text for computing error rates. Text is lower cased, numbers are spelled out in words,
+// and punctuation is removed.
+func NormalizeText(text string) string {
+	var words []string
+	var current strings.Builder
+	var number strings.Builder
+
+	flushNumber := func() {
+		if number.Len() > 0 {
+			words = append(words, spellNumber(strings.TrimRight(number.String(), ",."))...)
+			number.Reset()
+		}
+	}
+	flushWord := func() {
+		if current.Len() > 0 {
+			words = append(words, current.String())
+			current.Reset()
+		}
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		switch {
+		case unicode.IsDigit(r) && r < unicode.MaxASCII:
+			flushWord()
+			number.WriteRune(r)
+		case (r == ',' || r == '.') && number.Len() > 0 && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			number.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushNumber()
+			current.WriteRune(unicode.ToLower(r))
+		case r == '\'' || r == '’':
+			// keep contractions together, "don't" becomes "dont"
+			flushNumber()
+		default:
+			flushNumber()
+			flushWord()
+		}
+	}
+	flushNumber()
+	flushWord()
+
+	return strings.Join(words, " ")
+}
+
+// Align computes the minimum edit distance alignment of hyp to ref and returns the steps of the alignment along
+// with the error counts.
+func Align(ref, hyp []string) ([]AlignmentStep, ErrorCounts) {
+	// costs[i][j] is the edit distance between ref[:i] and hyp[:j]
+	costs := make([][]int, len(ref)+1)
+	for i := range costs {
+		costs[i] = make([]int, len(hyp)+1)
+		costs[i][0] = i
+	}
+	for j := range costs[0] {
+		costs[0][j] = j
+	}
+
+	for i := 1; i <= len(ref); i += 1 {
+		for j := 1; j <= len(hyp); j += 1 {
+			substitutionCost := 1
+			if ref[i-1] == hyp[j-1] {
+				substitutionCost = 0
+			}
+			costs[i][j] = min(costs[i-1][j-1]+substitutionCost, costs[i-1][j]+1, costs[i][j-1]+1)
+		}
+	}
+
+	var steps []AlignmentStep
+	counts := ErrorCounts{Reference: len(ref)}
+	for i, j := len(ref), len(hyp); i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && ref[i-1] == hyp[j-1] && costs[i][j] == costs[i-1][j-1]:
+			steps = append(steps, AlignmentStep{Op: ALIGN_CORRECT, Ref: ref[i-1], Hyp: hyp[j-1]})
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && costs[i][j] == costs[i-1][j-1]+1:
+			steps = append(steps, AlignmentStep{Op: ALIGN_SUBSTITUTION, Ref: ref[i-1], Hyp: hyp[j-1]})
+			counts.Substitutions += 1
+			i, j = i-1, j-1
+		case i > 0 && costs[i][j] == costs[i-1][j]+1:
+			steps = append(steps, AlignmentStep{Op: ALIGN_DELETION, Ref: ref[i-1]})
+			counts.Deletions += 1
+			i -= 1
+		default:
+			steps = append(steps, AlignmentStep{Op: ALIGN_INSERTION, Hyp: hyp[j-1]})
+			counts.Insertions += 1
+			j -= 1
+		}
+	}
+
+	for left, right := 0, len(steps)-1; left < right; left, right = left+1, right-1 {
+		steps[left], steps[right] = steps[right], steps[left]
+	}
+
+	return steps, counts
+}
+
+// AlignWords aligns the words of the normalized hypothesis text to the normalized reference text.
+func AlignWords(ref, hyp string) ([]AlignmentStep, ErrorCounts) {
+	return Align(strings.Fields(NormalizeText(ref)), strings.Fields(NormalizeText(hyp)))
+}
+
+// CharacterErrors returns the character level error counts of the normalized hypothesis text compared to the
+// normalized reference text.
+func CharacterErrors(ref, hyp string) ErrorCounts {
+	split := func(text string) []string {
+		var characters []string
+		for _, r := range NormalizeText(text) {
+			characters = append(characters, string(r))
+		}
+		return characters
+	}
+
+	_, counts := Align(split(ref), split(hyp))
+	return counts
+}
+
+// FormatAlignment returns three lines showing the reference, the hypothesis, and the operation for each step.
+func FormatAlignment(steps []AlignmentStep) (string, string, string) {
+	var refLine, hypLine, opLine strings.Builder
+
+	for i, step := range steps {
+		if i > 0 {
+			refLine.WriteByte(' ')
+			hypLine.WriteByte(' ')
+			opLine.WriteByte(' ')
+		}
+
+		ref, hyp := step.Ref, step.Hyp
+		if ref == "" {
+			ref = strings.Repeat("*", len([]rune(hyp)))
+		}
+		if hyp == "" {
+			hyp = strings.Repeat("*", len([]rune(ref)))
+		}
+		width := max(len([]rune(ref)), len([]rune(hyp)))
+
+		op := " "
+		if step.Op != ALIGN_CORRECT {
+			op = string(step.Op)
+		}
+
+		refLine.WriteString(ref + strings.Repeat(" ", width-len([]rune(ref))))
+		hypLine.WriteString(hyp + strings.Repeat(" ", width-len([]rune(hyp))))
+		opLine.WriteString(op + strings.Repeat(" ", width-1))
+	}
+
+	return refLine.String(), hypLine.String(), strings.TrimRight(opLine.String(), " ")
+}
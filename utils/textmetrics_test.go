@@ -0,0 +1,23 @@
+package utils
+
+import "testing"
+
+func TestNormalizeTextNumbers(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "3.14", want: "three point one four"},
+		{text: "1.2.3", want: "one point two point three"},
+		{text: "192.168.1.1", want: "one hundred ninety two point one six eight point one point one"},
+		{text: "5.", want: "five"},
+		{text: "It costs 1,234 dollars.", want: "it costs one thousand two hundred thirty four dollars"},
+	}
+
+	for _, test := range tests {
+		got := NormalizeText(test.text)
+		if got != test.want {
+			t.Errorf("NormalizeText(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TextChunk is a piece of a longer text that is short enough to be synthesized on its own.
+type TextChunk struct {
+	Text string
+	// Paragraph is the index of the paragraph the chunk was taken from.
+	Paragraph int
+}
+
+var paragraphSeparator = regexp.MustCompile(`\n[ \t\r]*\n`)
+
+// sentenceTerminators lists the characters ending a sentence for each language. Languages that are not listed
+// use the "" entry.
+var sentenceTerminators = map[string]string{
+	"":   ".!?…",
+	"zh": ".!?…。！？",
+	"ja": ".!?…。！？",
+	"hi": ".!?…।",
+	"ar": ".!?…؟",
+	"fa": ".!?…؟",
+	"ur": ".!?…؟۔",
+	"el": ".!…;",
+	"hy": ".!?…։",
+	"am": ".!?…።",
+}
+
+// fullWidthTerminators end a sentence even when they are not followed by a space.
+const fullWidthTerminators = "。！？"
+
+// abbreviations lists the lower cased words for each language that end with a period without ending a sentence.
+var abbreviations = map[string][]string{
+	"":   {"mr", "mrs", "ms", "dr", "prof", "sr", "jr", "st", "vs", "etc", "e.g", "i.e", "no", "approx", "inc", "ltd", "co", "mt", "jan", "feb", "mar", "apr", "jun", "jul", "aug", "sep", "sept", "oct", "nov", "dec"},
+	"de": {"dr", "prof", "hr", "fr", "nr", "str", "bzw", "usw", "z.b", "d.h", "u.a", "ca", "evtl", "ggf", "vgl", "bspw"},
+	"fr": {"m", "mm", "mme", "mlle", "dr", "pr", "st", "ste", "etc", "cf", "env", "p.ex"},
+	"es": {"sr", "sra", "srta", "dr", "dra", "ud", "uds", "etc", "p.ej", "núm", "av"},
+	"it": {"sig", "sigg", "dott", "prof", "ing", "avv", "ecc", "es"},
+	"nl": {"dhr", "mevr", "dr", "prof", "bijv", "o.a", "d.w.z", "enz", "nr"},
+	"pt": {"sr", "sra", "dr", "dra", "prof", "etc", "ex", "nº"},
+}
+
+// baseLanguage returns the lower cased language code of a locale such as "en_US" or "pt-BR".
+func baseLanguage(language string) string {
+	language = strings.ToLower(language)
+	if i := strings.IndexAny(language, "_-"); i >= 0 {
+		language = language[:i]
+	}
+	return language
+}
+
+func isAbbreviation(word, language string) bool {
+	word = strings.ToLower(strings.TrimRight(word, "."))
+	word = strings.TrimLeftFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+	if word == "" {
+		return false
+	}
+
+	// initials such as the "J" in "J. Smith"
+	if len([]rune(word)) == 1 && language != "fr" {
+		return true
+	}
+
+	for _, list := range [][]string{abbreviations[language], abbreviations[""]} {
+		for _, abbreviation := range list {
+			if word == abbreviation {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// SplitSentences splits text into sentences using the punctuation rules for language. Periods after common
+// abbreviations, initials and inside numbers do not end a sentence.
+func SplitSentences(text, language string) []string {
+	language = baseLanguage(language)
+	terminators, ok := sentenceTerminators[language]
+	if !ok {
+		terminators = sentenceTerminators[""]
+	}
+
+	runes := []rune(text)
+	var sentences []string
+	start := 0
+	for i := 0; i < len(runes); i += 1 {
+		if !strings.ContainsRune(terminators, runes[i]) {
+			continue
+		}
+
+		// include repeated terminators and closing quotes or brackets
+		end := i + 1
+		for end < len(runes) && (strings.ContainsRune(terminators, runes[end]) || strings.ContainsRune(`"'”’»)]`, runes[end])) {
+			end += 1
+		}
+
+		if !strings.ContainsRune(fullWidthTerminators, runes[i]) {
+			if end < len(runes) && !unicode.IsSpace(runes[end]) {
+				i = end - 1
+				continue
+			}
+			if runes[i] == '.' && end == i+1 {
+				words := strings.Fields(string(runes[start:i]))
+				if len(words) > 0 && isAbbreviation(words[len(words)-1], language) {
+					continue
+				}
+			}
+		}
+
+		if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = end
+		i = end - 1
+	}
+
+	if sentence := strings.TrimSpace(string(runes[start:])); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+
+	return sentences
+}
+
+// splitLongSentence splits sentence into pieces of at most maxChars characters, preferring to split after clause
+// punctuation, then between words.
+func splitLongSentence(sentence string, maxChars int) []string {
+	var pieces []string
+	runes := []rune(sentence)
+	for len(runes) > maxChars {
+		cut := -1
+		for i := maxChars - 1; i > 0 && cut < 0; i -= 1 {
+			if strings.ContainsRune(",;:、，；：", runes[i]) {
+				cut = i + 1
+			}
+		}
+		for i := maxChars; i > 0 && cut < 0; i -= 1 {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+			}
+		}
+		if cut < 0 {
+			cut = maxChars
+		}
+
+		if piece := strings.TrimSpace(string(runes[:cut])); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+	if piece := strings.TrimSpace(string(runes)); piece != "" {
+		pieces = append(pieces, piece)
+	}
+
+	return pieces
+}
+
+// SplitTextIntoChunks splits text into paragraphs, separated by blank lines, and the paragraphs into chunks of
+// whole sentences up to maxChars characters long. Sentences longer than maxChars are split at clause punctuation
+// or between words. Every sentence is its own chunk if maxChars is 0 or less.
+func SplitTextIntoChunks(text, language string, maxChars int) []TextChunk {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var chunks []TextChunk
+	paragraphIndex := 0
+	for _, paragraph := range paragraphSeparator.Split(text, -1) {
+		paragraph = strings.Join(strings.Fields(paragraph), " ")
+		if paragraph == "" {
+			continue
+		}
+
+		var current string
+		for _, sentence := range SplitSentences(paragraph, language) {
+			pieces := []string{sentence}
+			if maxChars > 0 && len([]rune(sentence)) > maxChars {
+				pieces = splitLongSentence(sentence, maxChars)
+			}
+
+			for _, piece := range pieces {
+				if current != "" && (maxChars <= 0 || len([]rune(current))+1+len([]rune(piece)) > maxChars) {
+					chunks = append(chunks, TextChunk{Text: current, Paragraph: paragraphIndex})
+					current = ""
+				}
+				if current == "" {
+					current = piece
+				} else {
+					current += " " + piece
+				}
+			}
+		}
+		if current != "" {
+			chunks = append(chunks, TextChunk{Text: current, Paragraph: paragraphIndex})
+		}
+
+		paragraphIndex += 1
+	}
+
+	return chunks
+}
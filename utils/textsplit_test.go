@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		text     string
+		language string
+		want     []string
+	}{
+		{text: "Hello there. How are you? Fine!", language: "en_US", want: []string{"Hello there.", "How are you?", "Fine!"}},
+		{text: "Dr. Smith met J. Doe at 3.30 today. They talked.", language: "en_US", want: []string{"Dr. Smith met J. Doe at 3.30 today.", "They talked."}},
+		{text: "Wait... what?! \"Really.\" Yes.", language: "en", want: []string{"Wait...", "what?!", "\"Really.\"", "Yes."}},
+		{text: "Visit example.com now. Thanks", language: "en", want: []string{"Visit example.com now.", "Thanks"}},
+		{text: "Das ist z.B. gut. Nr. 5 ist besser.", language: "de_DE", want: []string{"Das ist z.B. gut.", "Nr. 5 ist besser."}},
+		{text: "你好。今天天气很好！", language: "zh_CN", want: []string{"你好。", "今天天气很好！"}},
+		{text: "Τι κάνεις; Καλά.", language: "el_GR", want: []string{"Τι κάνεις;", "Καλά."}},
+		{text: "   ", language: "en", want: nil},
+	}
+
+	for _, test := range tests {
+		got := SplitSentences(test.text, test.language)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitSentences(%q, %q) = %q, want %q", test.text, test.language, got, test.want)
+		}
+	}
+}
+
+func TestSplitLongSentence(t *testing.T) {
+	tests := []struct {
+		sentence string
+		maxChars int
+		want     []string
+	}{
+		{sentence: "short sentence", maxChars: 20, want: []string{"short sentence"}},
+		{sentence: "first part, second part of it", maxChars: 20, want: []string{"first part,", "second part of it"}},
+		{sentence: "one two three four five", maxChars: 10, want: []string{"one two", "three four", "five"}},
+		{sentence: "abcdefghijkl", maxChars: 5, want: []string{"abcde", "fghij", "kl"}},
+	}
+
+	for _, test := range tests {
+		got := splitLongSentence(test.sentence, test.maxChars)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitLongSentence(%q, %d) = %q, want %q", test.sentence, test.maxChars, got, test.want)
+		}
+	}
+}
+
+func TestSplitTextIntoChunks(t *testing.T) {
+	text := "One two. Three four.\r\n\r\n  Five six seven eight nine ten. Eleven.\n \nTwelve."
+
+	tests := []struct {
+		maxChars int
+		want     []TextChunk
+	}{
+		{
+			maxChars: 0,
+			want: []TextChunk{
+				{Text: "One two.", Paragraph: 0},
+				{Text: "Three four.", Paragraph: 0},
+				{Text: "Five six seven eight nine ten.", Paragraph: 1},
+				{Text: "Eleven.", Paragraph: 1},
+				{Text: "Twelve.", Paragraph: 2},
+			},
+		},
+		{
+			maxChars: 40,
+			want: []TextChunk{
+				{Text: "One two. Three four.", Paragraph: 0},
+				{Text: "Five six seven eight nine ten. Eleven.", Paragraph: 1},
+				{Text: "Twelve.", Paragraph: 2},
+			},
+		},
+		{
+			maxChars: 20,
+			want: []TextChunk{
+				{Text: "One two. Three four.", Paragraph: 0},
+				{Text: "Five six seven eight", Paragraph: 1},
+				{Text: "nine ten. Eleven.", Paragraph: 1},
+				{Text: "Twelve.", Paragraph: 2},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := SplitTextIntoChunks(text, "en_US", test.maxChars)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitTextIntoChunks(%q, %d) = %+v, want %+v", text, test.maxChars, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+const VAD_PEAK string = "peak"
+const VAD_ENERGY string = "energy"
+const VAD_SPECTRAL string = "spectral"
+
+// VADResult describes how a VoiceActivityDetector classified one window of audio. Sound and Silence are
+// separate so that detectors can leave a gap between the two, like the sound and silence thresholds used
+// by DetectNextAudioGroup16Bit. A window that is neither continues whichever event is currently being detected.
+type VADResult struct {
+	Probability float64
+	Sound       bool
+	Silence     bool
+}
+
+// VoiceActivityDetector classifies windows of interleaved 16-bit audio samples. Detectors may keep state
+// between calls, such as a noise floor estimate, so a detector should only be used for a single stream.
+type VoiceActivityDetector interface {
+	ProcessFrame(frame []int16, rate, channels int) VADResult
+}
+
+// PeakDetector compares the difference between the largest and smallest sample in a window to fixed thresholds.
+type PeakDetector struct {
+	SoundThreshold   int32
+	SilenceThreshold int32
+}
+
+// ProcessFrame implements VoiceActivityDetector.
+func (p *PeakDetector) ProcessFrame(frame []int16, rate, channels int) VADResult {
+	var highestValue int32 = math.MinInt16
+	var lowestValue int32 = math.MaxInt16
+	for _, sample := range frame {
+		highestValue = max(highestValue, int32(sample))
+		lowestValue = min(lowestValue, int32(sample))
+	}
+
+	peakToPeak := highestValue - lowestValue
+	if len(frame) == 0 {
+		peakToPeak = 0
+	}
+
+	return VADResult{
+		Probability: math.Min(float64(peakToPeak)/float64(max(p.SoundThreshold, 1)), 1),
+		Sound:       peakToPeak > p.SoundThreshold,
+		Silence:     peakToPeak < p.SilenceThreshold,
+	}
+}
+
+// AudioSegmenter splits a stream of audio into segments containing sound.
+type AudioSegmenter interface {
+	// NextAudioGroup reads from reader until the next segment of audio is detected. Start and End are relative
+	// to the first audio read by the AudioSegmenter.
+	NextAudioGroup(reader io.Reader) (AudioEvent, error)
+}
+
+// Segmenter splits 16-bit PCM audio into AudioEvent segments using Detector. A segment starts after MinSoundDurationMS
+// of consecutive sound windows and ends after MinSilenceDurationMS of consecutive silence windows. Up to PreRollMS of
+// the audio before the sound and PostRollMS of the silence after it are included in each segment. If MaxSegmentMS is
+// set, segments reaching that length are split at the quietest window near the limit and the remaining audio starts the
+// next segment. Segmenter implements AudioSegmenter.
+type Segmenter struct {
+	Detector             VoiceActivityDetector
+	Rate                 int
+	Channels             int
+	AudioWindowMS        int
+	MinSoundDurationMS   int
+	MinSilenceDurationMS int
+	PreRollMS            int
+	PostRollMS           int
+	MaxSegmentMS         int
+
+	offsetMS     int
+	history      *RingBuffer
+	lastEndByte  int64
+	carry        []byte
+	carryLevels  []float64
+	carryStartMS int
+}
+
+// windowSize returns the number of samples read for each window.
+func (s *Segmenter) windowSize() int {
+	return int((float64(s.Rate) / (1000 / float64(s.AudioWindowMS))) * float64(s.Channels))
+}
+
+// readWindow16Bits reads windowSize samples from reader one at a time so that a partial window at the end of
+// the audio results in an io.EOF error like DetectAudioEvent16Bits.
+func readWindow16Bits(reader io.Reader, windowSize int) ([]int16, error) {
+	samples := make([]int16, windowSize)
+	for i := range samples {
+		err := binary.Read(reader, binary.LittleEndian, &samples[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return samples, nil
+}
+
+// detectAudioEvent reads one window from reader and reports if it matches mode.
+func (s *Segmenter) detectAudioEvent(reader io.Reader, mode int) (bool, error) {
+	if mode != DETECT_NOISE_MODE && mode != DETECT_SILENCE_MODE {
+		return false, errors.New("invalid mode")
+	}
+	windowSize := s.windowSize()
+	if windowSize <= 0 {
+		return false, errors.New("invalid window size")
+	}
+
+	frame, err := readWindow16Bits(reader, windowSize)
+	if err != nil {
+		return false, err
+	}
+
+	result := s.Detector.ProcessFrame(frame, s.Rate, s.Channels)
+	if mode == DETECT_NOISE_MODE {
+		return result.Sound, nil
+	}
+	return result.Silence, nil
+}
+
+// bytesForMS returns the number of bytes in durationMS of audio. durationMS should be a multiple of AudioWindowMS.
+func (s *Segmenter) bytesForMS(durationMS int) int64 {
+	return int64(durationMS/s.AudioWindowMS) * int64(s.windowSize()) * 2
+}
+
+// durationForBytes returns the duration of byteCount bytes of audio.
+func (s *Segmenter) durationForBytes(byteCount int) time.Duration {
+	return time.Duration(float64(byteCount) / float64(s.Rate*s.Channels*2) * float64(time.Second))
+}
+
+// detectAudioEventDuration reads from reader until durationMS of consecutive windows matching detectMode are found.
+func (s *Segmenter) detectAudioEventDuration(reader io.Reader, durationMS, detectMode int) (int, bytes.Buffer, error) {
+	return detectAudioEventDuration(reader, durationMS, s.AudioWindowMS, detectMode, func(r io.Reader) (bool, error) {
+		return s.detectAudioEvent(r, detectMode)
+	})
+}
+
+// readFrame reads one window from reader and returns the samples along with the bytes read.
+func (s *Segmenter) readFrame(reader io.Reader) ([]int16, []byte, error) {
+	raw := bytes.Buffer{}
+	frame, err := readWindow16Bits(io.TeeReader(reader, &raw), s.windowSize())
+	return frame, raw.Bytes(), err
+}
+
+// windowLevels returns the level in dBFS of each full window in audio.
+func (s *Segmenter) windowLevels(audio []byte) []float64 {
+	windowSize := s.windowSize()
+	levels := make([]float64, len(audio)/(windowSize*2))
+	for i := range levels {
+		frame := make([]int16, windowSize)
+		binary.Read(bytes.NewReader(audio[i*windowSize*2:(i+1)*windowSize*2]), binary.LittleEndian, frame)
+		levels[i] = RMSDBFS16Bits(frame)
+	}
+
+	return levels
+}
+
+// quietestWindow returns the index of the quietest window in the last fifth of levels.
+func (s *Segmenter) quietestWindow(levels []float64) int {
+	searchWindows := max(len(levels)/5, 1)
+
+	quietest := len(levels) - 1
+	for i := len(levels) - searchWindows; i < len(levels); i += 1 {
+		if levels[i] < levels[quietest] {
+			quietest = i
+		}
+	}
+
+	return quietest
+}
+
+// NextAudioGroup reads from reader and detects the next segment of audio. The AudioEvent returned contains the start
+// and end time and a buffer containing the audio data, including any pre-roll and post-roll.
+func (s *Segmenter) NextAudioGroup(reader io.Reader) (AudioEvent, error) {
+	if s.Detector == nil {
+		return AudioEvent{}, errors.New("missing voice activity detector")
+	}
+
+	// keep enough history to cover the pre-roll before the sound that is detected
+	if s.PreRollMS > 0 {
+		if s.history == nil {
+			s.history = NewRingBuffer(int(s.bytesForMS(s.PreRollMS + s.MinSoundDurationMS + s.AudioWindowMS)))
+		}
+		reader = io.TeeReader(reader, s.history)
+	}
+
+	var startTimeMS int
+	var segment bytes.Buffer
+	var levels []float64
+	var preRoll []byte
+
+	continued := s.carry != nil
+	if continued {
+		startTimeMS = s.carryStartMS
+		segment.Write(s.carry)
+		levels = s.carryLevels
+		s.carry = nil
+		s.carryLevels = nil
+	} else {
+		soundOffsetMS, soundBuff, err := s.detectAudioEventDuration(reader, s.MinSoundDurationMS, DETECT_NOISE_MODE)
+		if err != nil {
+			return AudioEvent{}, err
+		}
+
+		startTimeMS = soundOffsetMS + s.offsetMS
+		s.offsetMS = startTimeMS + s.MinSoundDurationMS
+
+		if s.history != nil {
+			startByte := s.bytesForMS(startTimeMS)
+			preRoll = s.history.Slice(max(startByte-s.bytesForMS(s.PreRollMS), s.lastEndByte), startByte)
+		}
+
+		segment = soundBuff
+		levels = s.windowLevels(soundBuff.Bytes())
+	}
+
+	// read until enough silence is found, the audio ends, or the segment reaches MaxSegmentMS
+	silenceStart := -1
+	split := false
+	for {
+		frame, raw, err := s.readFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return AudioEvent{}, err
+			}
+
+			segment.Write(raw)
+			break
+		}
+
+		s.offsetMS += s.AudioWindowMS
+		segment.Write(raw)
+		levels = append(levels, RMSDBFS16Bits(frame))
+
+		if s.Detector.ProcessFrame(frame, s.Rate, s.Channels).Silence {
+			if silenceStart < 0 {
+				silenceStart = len(levels) - 1
+			}
+			if (len(levels)-silenceStart)*s.AudioWindowMS >= s.MinSilenceDurationMS {
+				break
+			}
+		} else {
+			silenceStart = -1
+		}
+
+		if s.MaxSegmentMS > 0 && len(levels)*s.AudioWindowMS >= s.MaxSegmentMS {
+			split = silenceStart < 0
+			break
+		}
+	}
+
+	windowBytes := s.windowSize() * 2
+	endWindows := len(levels)
+	endByte := segment.Len()
+	if split {
+		endWindows = s.quietestWindow(levels) + 1
+		endByte = endWindows * windowBytes
+	} else if silenceStart >= 0 {
+		endWindows = silenceStart
+		endByte = endWindows * windowBytes
+	}
+	endTimeMS := startTimeMS + endWindows*s.AudioWindowMS
+
+	var postRoll []byte
+	if split {
+		s.carry = bytes.Clone(segment.Bytes()[endByte:])
+		s.carryLevels = levels[endWindows:]
+		s.carryStartMS = endTimeMS
+	} else {
+		postRoll = segment.Bytes()[endByte:min(endByte+int(s.bytesForMS(s.PostRollMS)), segment.Len())]
+	}
+	s.lastEndByte = s.bytesForMS(endTimeMS) + int64(len(postRoll))
+
+	audioEvent := AudioEvent{
+		Start:     time.Millisecond*time.Duration(startTimeMS) - s.durationForBytes(len(preRoll)),
+		End:       time.Millisecond*time.Duration(endTimeMS) + s.durationForBytes(len(postRoll)),
+		Split:     split,
+		Continued: continued,
+	}
+	audioEvent.SoundBuff.Grow(len(preRoll) + endByte + len(postRoll))
+	audioEvent.SoundBuff.Write(preRoll)
+	audioEvent.SoundBuff.Write(segment.Bytes()[:endByte])
+	audioEvent.SoundBuff.Write(postRoll)
+
+	return audioEvent, nil
+}
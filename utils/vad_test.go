@@ -0,0 +1,370 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+const TEST_RATE int = 16000
+
+// testPart is durationMS of a 500 Hz tone with the given amplitude, or silence if amplitude is 0. Every 10 ms window
+// of the tone holds whole periods so all of them have the same level.
+type testPart struct {
+	durationMS int
+	amplitude  float64
+}
+
+// testSamples returns mono samples at TEST_RATE made of parts.
+func testSamples(parts ...testPart) []int16 {
+	var samples []int16
+	for _, part := range parts {
+		for i := 0; i < TEST_RATE*part.durationMS/1000; i += 1 {
+			samples = append(samples, int16(part.amplitude*math.Sin(2*math.Pi*float64(i%32)/32)))
+		}
+	}
+	return samples
+}
+
+// testAudio returns the bytes of mono 16-bit audio at TEST_RATE made of parts.
+func testAudio(parts ...testPart) []byte {
+	var audio bytes.Buffer
+	binary.Write(&audio, binary.LittleEndian, testSamples(parts...))
+	return audio.Bytes()
+}
+
+// testNoise returns count samples of white noise with the given amplitude.
+func testNoise(count int, amplitude float64) []int16 {
+	random := rand.New(rand.NewSource(1))
+	samples := make([]int16, count)
+	for i := range samples {
+		samples[i] = int16((random.Float64()*2 - 1) * amplitude)
+	}
+	return samples
+}
+
+// testSegment is the part of an AudioEvent compared by the segmenter tests.
+type testSegment struct {
+	startMS   int
+	endMS     int
+	split     bool
+	continued bool
+}
+
+// readSegments calls segmenter until it returns io.EOF and checks that the audio of each segment matches its times.
+func readSegments(t *testing.T, segmenter AudioSegmenter, audio []byte) []testSegment {
+	t.Helper()
+
+	var segments []testSegment
+	reader := bytes.NewReader(audio)
+	for {
+		audioEvent, err := segmenter.NextAudioGroup(reader)
+		if errors.Is(err, io.EOF) {
+			return segments
+		}
+		if err != nil {
+			t.Fatalf("NextAudioGroup returned error %v", err)
+		}
+
+		segment := testSegment{
+			startMS:   int(audioEvent.Start.Round(time.Millisecond).Milliseconds()),
+			endMS:     int(audioEvent.End.Round(time.Millisecond).Milliseconds()),
+			split:     audioEvent.Split,
+			continued: audioEvent.Continued,
+		}
+		if wantBytes := (segment.endMS - segment.startMS) * TEST_RATE / 1000 * 2; audioEvent.SoundBuff.Len() != wantBytes {
+			t.Errorf("segment %+v has %d bytes of audio, want %d", segment, audioEvent.SoundBuff.Len(), wantBytes)
+		}
+		segments = append(segments, segment)
+	}
+}
+
+func TestPeakDetector(t *testing.T) {
+	tests := []struct {
+		frame   []int16
+		sound   bool
+		silence bool
+	}{
+		{frame: testSamples(testPart{durationMS: 10}), sound: false, silence: true},
+		{frame: testSamples(testPart{durationMS: 10, amplitude: 200}), sound: false, silence: true},
+		{frame: testSamples(testPart{durationMS: 10, amplitude: 400}), sound: false, silence: false},
+		{frame: testSamples(testPart{durationMS: 10, amplitude: 8000}), sound: true, silence: false},
+		{frame: nil, sound: false, silence: true},
+	}
+
+	detector := PeakDetector{SoundThreshold: 1000, SilenceThreshold: 500}
+	for i, test := range tests {
+		got := detector.ProcessFrame(test.frame, TEST_RATE, 1)
+		if got.Sound != test.sound || got.Silence != test.silence {
+			t.Errorf("test %d: ProcessFrame = %+v, want sound %v and silence %v", i, got, test.sound, test.silence)
+		}
+	}
+}
+
+func TestRMSDBFS16Bits(t *testing.T) {
+	tests := []struct {
+		samples []int16
+		want    float64
+	}{
+		{samples: nil, want: MIN_DBFS},
+		{samples: []int16{0, 0, 0}, want: MIN_DBFS},
+		{samples: []int16{math.MaxInt16, -math.MaxInt16}, want: 0},
+		{samples: []int16{3277, -3277}, want: -20},
+	}
+
+	for _, test := range tests {
+		got := RMSDBFS16Bits(test.samples)
+		if math.Abs(got-test.want) > 0.01 {
+			t.Errorf("RMSDBFS16Bits(%v) = %v, want %v", test.samples, got, test.want)
+		}
+	}
+}
+
+func TestEnergyDetector(t *testing.T) {
+	quiet := testPart{durationMS: 10, amplitude: 100}
+	loud := testPart{durationMS: 10, amplitude: 8000}
+	click := testSamples(quiet)
+	// a click loud enough for PeakDetector with the thresholds used by TestPeakDetector
+	click[80] = 3000
+
+	tests := []struct {
+		name    string
+		frames  [][]int16
+		sound   bool
+		silence bool
+	}{
+		{name: "background", frames: [][]int16{testSamples(quiet), testSamples(quiet)}, sound: false, silence: true},
+		{name: "speech", frames: [][]int16{testSamples(quiet), testSamples(quiet), testSamples(loud)}, sound: true, silence: false},
+		{name: "click", frames: [][]int16{testSamples(quiet), testSamples(quiet), click}, sound: false, silence: false},
+		{name: "loud background", frames: [][]int16{testSamples(loud), testSamples(loud), testSamples(loud)}, sound: false, silence: true},
+	}
+
+	for _, test := range tests {
+		detector := NewEnergyDetector(12, 6)
+		var got VADResult
+		for _, frame := range test.frames {
+			got = detector.ProcessFrame(frame, TEST_RATE, 1)
+		}
+		if got.Sound != test.sound || got.Silence != test.silence {
+			t.Errorf("%s: ProcessFrame = %+v, want sound %v and silence %v", test.name, got, test.sound, test.silence)
+		}
+	}
+}
+
+func TestZeroCrossingRate(t *testing.T) {
+	tests := []struct {
+		samples []float64
+		want    float64
+	}{
+		{samples: nil, want: 0},
+		{samples: []float64{1, 2, 3, 4}, want: 0},
+		{samples: []float64{1, -1, 1, -1, 1}, want: 1},
+		{samples: []float64{1, -1, -1, -1, -1}, want: 0.25},
+	}
+
+	for _, test := range tests {
+		got := ZeroCrossingRate(test.samples)
+		if got != test.want {
+			t.Errorf("ZeroCrossingRate(%v) = %v, want %v", test.samples, got, test.want)
+		}
+	}
+}
+
+func TestSpectralFlatness(t *testing.T) {
+	toFloats := func(samples []int16) []float64 {
+		return mixToMono16Bits(samples, 1)
+	}
+
+	tests := []struct {
+		name    string
+		samples []float64
+		min     float64
+		max     float64
+	}{
+		{name: "tone", samples: toFloats(testSamples(testPart{durationMS: 32, amplitude: 8000})), min: 0, max: 0.1},
+		{name: "noise", samples: toFloats(testNoise(512, 8000)), min: 0.4, max: 1},
+	}
+
+	for _, test := range tests {
+		got := SpectralFlatness(test.samples)
+		if got < test.min || got > test.max {
+			t.Errorf("%s: SpectralFlatness = %v, want between %v and %v", test.name, got, test.min, test.max)
+		}
+	}
+}
+
+func TestSpectralDetector(t *testing.T) {
+	quiet := testSamples(testPart{durationMS: 30, amplitude: 100})
+
+	tests := []struct {
+		name    string
+		frame   []int16
+		sound   bool
+		silence bool
+	}{
+		{name: "speech", frame: testSamples(testPart{durationMS: 30, amplitude: 8000}), sound: true, silence: false},
+		{name: "noise", frame: testNoise(len(quiet), 8000), sound: false, silence: true},
+		{name: "background", frame: quiet, sound: false, silence: true},
+	}
+
+	for _, test := range tests {
+		detector := NewSpectralDetector(12, 6, 0.3)
+		detector.ProcessFrame(quiet, TEST_RATE, 1)
+		got := detector.ProcessFrame(test.frame, TEST_RATE, 1)
+		if got.Sound != test.sound || got.Silence != test.silence {
+			t.Errorf("%s: ProcessFrame = %+v, want sound %v and silence %v", test.name, got, test.sound, test.silence)
+		}
+	}
+}
+
+func TestMixToMono16Bits(t *testing.T) {
+	got := mixToMono16Bits([]int16{100, 300, -200, 0}, 2)
+	want := []float64{200, -100}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("mixToMono16Bits = %v, want %v", got, want)
+	}
+}
+
+// testSpeechAudio returns audio with sound from 200 to 700 ms and from 1000 to 1200 ms.
+func testSpeechAudio() []byte {
+	return testAudio(
+		testPart{durationMS: 200},
+		testPart{durationMS: 500, amplitude: 8000},
+		testPart{durationMS: 300},
+		testPart{durationMS: 200, amplitude: 8000},
+		testPart{durationMS: 200},
+	)
+}
+
+// compareSegments reports the differences between the segments got and want.
+func compareSegments(t *testing.T, name string, got, want []testSegment) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s: got segments %+v, want %+v", name, got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%s: segment %d = %+v, want %+v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestSegmenter(t *testing.T) {
+	tests := []struct {
+		name     string
+		detector VoiceActivityDetector
+		want     []testSegment
+	}{
+		{name: "peak", detector: &PeakDetector{SoundThreshold: 1000, SilenceThreshold: 500}, want: []testSegment{{startMS: 200, endMS: 700}, {startMS: 1000, endMS: 1200}}},
+		{name: "energy", detector: NewEnergyDetector(12, 6), want: []testSegment{{startMS: 200, endMS: 700}, {startMS: 1000, endMS: 1200}}},
+	}
+
+	for _, test := range tests {
+		segmenter := &Segmenter{
+			Detector:             test.detector,
+			Rate:                 TEST_RATE,
+			Channels:             1,
+			AudioWindowMS:        10,
+			MinSoundDurationMS:   30,
+			MinSilenceDurationMS: 100,
+		}
+		compareSegments(t, test.name, readSegments(t, segmenter, testSpeechAudio()), test.want)
+	}
+}
+
+func TestSegmenterPadding(t *testing.T) {
+	tests := []struct {
+		preRollMS  int
+		postRollMS int
+		want       []testSegment
+	}{
+		{preRollMS: 50, postRollMS: 40, want: []testSegment{{startMS: 150, endMS: 740}, {startMS: 950, endMS: 1240}}},
+		// the pre-roll is limited by the start of the audio and the end of the previous segment
+		{preRollMS: 300, postRollMS: 0, want: []testSegment{{startMS: 0, endMS: 700}, {startMS: 700, endMS: 1200}}},
+		{preRollMS: 300, postRollMS: 100, want: []testSegment{{startMS: 0, endMS: 800}, {startMS: 800, endMS: 1300}}},
+		// the post-roll is limited by the silence read to end the segment
+		{preRollMS: 0, postRollMS: 200, want: []testSegment{{startMS: 200, endMS: 800}, {startMS: 1000, endMS: 1300}}},
+	}
+
+	for _, test := range tests {
+		segmenter := &Segmenter{
+			Detector:             &PeakDetector{SoundThreshold: 1000, SilenceThreshold: 500},
+			Rate:                 TEST_RATE,
+			Channels:             1,
+			AudioWindowMS:        10,
+			MinSoundDurationMS:   30,
+			MinSilenceDurationMS: 100,
+			PreRollMS:            test.preRollMS,
+			PostRollMS:           test.postRollMS,
+		}
+		name := fmt.Sprintf("pre-roll %d post-roll %d", test.preRollMS, test.postRollMS)
+		compareSegments(t, name, readSegments(t, segmenter, testSpeechAudio()), test.want)
+	}
+}
+
+func TestSegmenterMissingDetector(t *testing.T) {
+	segmenter := &Segmenter{Rate: TEST_RATE, Channels: 1, AudioWindowMS: 10}
+	if _, err := segmenter.NextAudioGroup(bytes.NewReader(testAudio(testPart{durationMS: 100}))); err == nil {
+		t.Errorf("NextAudioGroup without a detector returned no error")
+	}
+}
+
+func TestSegmenterMaxSegment(t *testing.T) {
+	tests := []struct {
+		name  string
+		audio []byte
+		want  []testSegment
+	}{
+		{
+			name:  "constant",
+			audio: testAudio(testPart{durationMS: 200}, testPart{durationMS: 1000, amplitude: 8000}, testPart{durationMS: 300}),
+			want: []testSegment{
+				{startMS: 200, endMS: 600, split: true},
+				{startMS: 600, endMS: 1000, split: true, continued: true},
+				{startMS: 1000, endMS: 1200, continued: true},
+			},
+		},
+		{
+			// the first segment is split after the quieter window near its end
+			name: "quieter window",
+			audio: testAudio(
+				testPart{durationMS: 200},
+				testPart{durationMS: 340, amplitude: 8000},
+				testPart{durationMS: 10, amplitude: 3000},
+				testPart{durationMS: 650, amplitude: 8000},
+				testPart{durationMS: 300},
+			),
+			want: []testSegment{
+				{startMS: 200, endMS: 550, split: true},
+				{startMS: 550, endMS: 950, split: true, continued: true},
+				{startMS: 950, endMS: 1200, continued: true},
+			},
+		},
+		{
+			name:  "remainder shorter than the limit",
+			audio: testSpeechAudio(),
+			want:  []testSegment{{startMS: 200, endMS: 600, split: true}, {startMS: 600, endMS: 700, continued: true}, {startMS: 1000, endMS: 1200}},
+		},
+	}
+
+	for _, test := range tests {
+		segmenter := &Segmenter{
+			Detector:             &PeakDetector{SoundThreshold: 1000, SilenceThreshold: 500},
+			Rate:                 TEST_RATE,
+			Channels:             1,
+			AudioWindowMS:        10,
+			MinSoundDurationMS:   30,
+			MinSilenceDurationMS: 100,
+			MaxSegmentMS:         400,
+		}
+		compareSegments(t, test.name, readSegments(t, segmenter, test.audio), test.want)
+	}
+}
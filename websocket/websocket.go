@@ -0,0 +1,423 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TEXT_MESSAGE = 1
+const BINARY_MESSAGE = 2
+
+const continuationFrame = 0
+const closeFrame = 8
+const pingFrame = 9
+const pongFrame = 10
+
+// acceptGUID is appended to the client key to compute the Sec-WebSocket-Accept header, see RFC 6455.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DEFAULT_MAX_MESSAGE_SIZE is the largest message ReadMessage accepts unless Conn.MaxMessageSize is changed.
+const DEFAULT_MAX_MESSAGE_SIZE = 16 * 1024 * 1024
+
+// Conn is a WebSocket connection implementing the parts of RFC 6455 needed to exchange text and binary messages.
+// Extensions and subprotocols are not supported.
+type Conn struct {
+	// MaxMessageSize is the largest message in bytes ReadMessage accepts.
+	MaxMessageSize int
+
+	conn      net.Conn
+	reader    *bufio.Reader
+	client    bool
+	writeLock sync.Mutex
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, client bool) *Conn {
+	return &Conn{MaxMessageSize: DEFAULT_MAX_MESSAGE_SIZE, conn: conn, reader: reader, client: client}
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for the Sec-WebSocket-Key value key.
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerContainsToken returns true if the comma separated header values contain token, ignoring case.
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade upgrades the HTTP request r to a WebSocket connection. An error response is written to w if r is not a
+// valid WebSocket handshake or if checkOrigin returns false. A nil checkOrigin accepts every origin.
+func Upgrade(w http.ResponseWriter, r *http.Request, checkOrigin func(r *http.Request) bool) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket handshake must use GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("missing websocket upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decodedKey, err := base64.StdEncoding.DecodeString(key); err != nil || len(decodedKey) != 16 {
+		http.Error(w, "invalid websocket key", http.StatusBadRequest)
+		return nil, errors.New("invalid websocket key")
+	}
+	if checkOrigin != nil && !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, errors.New("websocket origin not allowed")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response writer does not support hijacking")
+	}
+	conn, readWriter, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return newConn(conn, readWriter.Reader, false), nil
+}
+
+// Dial opens a WebSocket connection to a "ws://" or "wss://" URL. tlsConfig is used for "wss://" URLs and may be
+// nil.
+func Dial(rawURL string, tlsConfig *tls.Config) (*Conn, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := parsedURL.Host
+	if parsedURL.Port() == "" {
+		switch parsedURL.Scheme {
+		case "ws":
+			host = net.JoinHostPort(parsedURL.Hostname(), "80")
+		case "wss":
+			host = net.JoinHostPort(parsedURL.Hostname(), "443")
+		}
+	}
+
+	var conn net.Conn
+	switch parsedURL.Scheme {
+	case "ws":
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		} else {
+			tlsConfig = tlsConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = parsedURL.Hostname()
+		}
+		conn, err = tls.Dial("tcp", host, tlsConfig)
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme %q", parsedURL.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        parsedURL,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+		Host: parsedURL.Host,
+	}
+
+	// the handshake must finish in a reasonable time, the deadline is cleared afterwards
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %s", response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: invalid accept key")
+	}
+	conn.SetDeadline(time.Time{})
+
+	return newConn(conn, reader, true), nil
+}
+
+// readFrame reads a single frame and returns whether it is the final frame of a message, its opcode and its
+// unmasked payload.
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+
+	final := header[0]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket extensions are not supported")
+	}
+	opcode := int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	if masked == c.client {
+		return false, 0, nil, errors.New("websocket frame has invalid masking")
+	}
+
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+
+	if opcode >= closeFrame && (length > 125 || !final) {
+		return false, 0, nil, errors.New("websocket control frame is invalid")
+	}
+	if length > uint64(c.MaxMessageSize) {
+		return false, 0, nil, errors.New("websocket message is too large")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return final, opcode, payload, nil
+}
+
+// writeFrame writes payload as a single final frame with opcode. Frames sent by clients are masked.
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	frame := []byte{0x80 | byte(opcode)}
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := start; i < len(frame); i += 1 {
+			frame[i] ^= mask[(i-start)%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// ReadMessage reads the next text or binary message and returns its type, TEXT_MESSAGE or BINARY_MESSAGE, with
+// its data. Ping frames are answered while reading. io.EOF is returned once the other side closes the connection.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+
+	for {
+		final, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case pingFrame:
+			if err := c.writeFrame(pongFrame, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			continue
+		case closeFrame:
+			// echo the status code back before closing
+			c.writeFrame(closeFrame, payload[:min(len(payload), 2)])
+			c.conn.Close()
+			return 0, nil, io.EOF
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, errors.New("websocket continuation frame without a message")
+			}
+		case TEXT_MESSAGE, BINARY_MESSAGE:
+			if messageType != 0 {
+				return 0, nil, errors.New("websocket message started before the last one finished")
+			}
+			messageType = opcode
+		default:
+			return 0, nil, fmt.Errorf("unknown websocket opcode %d", opcode)
+		}
+
+		message = append(message, payload...)
+		if len(message) > c.MaxMessageSize {
+			return 0, nil, errors.New("websocket message is too large")
+		}
+
+		if final {
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage writes data as a single message of messageType, TEXT_MESSAGE or BINARY_MESSAGE.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TEXT_MESSAGE && messageType != BINARY_MESSAGE {
+		return errors.New("invalid websocket message type")
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// Close sends a normal closure frame and closes the connection.
+func (c *Conn) Close() error {
+	c.writeFrame(closeFrame, []byte{0x03, 0xe8})
+	return c.conn.Close()
+}
+
+// NetConn returns a net.Conn that reads the data of received messages as one stream of bytes and sends each
+// write as a binary message.
+func (c *Conn) NetConn() net.Conn {
+	return &streamConn{Conn: c}
+}
+
+// streamConn implements net.Conn on top of a Conn.
+type streamConn struct {
+	*Conn
+	pending []byte
+}
+
+func (s *streamConn) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		_, message, err := s.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		s.pending = message
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *streamConn) Write(p []byte) (int, error) {
+	if err := s.WriteMessage(BINARY_MESSAGE, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *streamConn) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+func (s *streamConn) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+func (s *streamConn) SetDeadline(t time.Time) error {
+	return s.conn.SetDeadline(t)
+}
+
+func (s *streamConn) SetReadDeadline(t time.Time) error {
+	return s.conn.SetReadDeadline(t)
+}
+
+func (s *streamConn) SetWriteDeadline(t time.Time) error {
+	return s.conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,268 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recordingConn is a net.Conn that keeps everything written to it.
+type recordingConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (r *recordingConn) Write(p []byte) (int, error) {
+	return r.written.Write(p)
+}
+
+func (r *recordingConn) Close() error {
+	return nil
+}
+
+// testConn returns a Conn reading frames and the connection recording what it writes.
+func testConn(frames []byte, client bool) (*Conn, *recordingConn) {
+	conn := &recordingConn{}
+	return newConn(conn, bufio.NewReader(bytes.NewReader(frames)), client), conn
+}
+
+func TestAcceptKey(t *testing.T) {
+	// the example from RFC 6455
+	got := acceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	if want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("acceptKey = %q, want %q", got, want)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		frames      []byte
+		client      bool
+		wantType    int
+		wantMessage string
+		wantWritten []byte
+	}{
+		{
+			name:        "unmasked text",
+			frames:      []byte{0x81, 0x05, 'H', 'e', 'l', 'l', 'o'},
+			client:      true,
+			wantType:    TEXT_MESSAGE,
+			wantMessage: "Hello",
+		},
+		{
+			name:        "masked text",
+			frames:      []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+			client:      false,
+			wantType:    TEXT_MESSAGE,
+			wantMessage: "Hello",
+		},
+		{
+			name:        "fragmented binary with a ping",
+			frames:      []byte{0x02, 0x03, 'H', 'e', 'l', 0x89, 0x02, 'h', 'i', 0x80, 0x02, 'l', 'o'},
+			client:      true,
+			wantType:    BINARY_MESSAGE,
+			wantMessage: "Hello",
+			// clients mask their frames so only the header of the pong is predictable
+			wantWritten: []byte{0x8a, 0x82},
+		},
+		{
+			name:        "16-bit length",
+			frames:      append([]byte{0x82, 0x7e, 0x01, 0x00}, bytes.Repeat([]byte{'a'}, 256)...),
+			client:      true,
+			wantType:    BINARY_MESSAGE,
+			wantMessage: strings.Repeat("a", 256),
+		},
+		{
+			name:        "64-bit length",
+			frames:      append([]byte{0x82, 0x7f, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00}, bytes.Repeat([]byte{'b'}, 65536)...),
+			client:      true,
+			wantType:    BINARY_MESSAGE,
+			wantMessage: strings.Repeat("b", 65536),
+		},
+	}
+
+	for _, test := range tests {
+		conn, written := testConn(test.frames, test.client)
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			t.Errorf("%s: ReadMessage returned error %v", test.name, err)
+			continue
+		}
+		if messageType != test.wantType || string(message) != test.wantMessage {
+			t.Errorf("%s: ReadMessage = %d, %q, want %d, %q", test.name, messageType, message, test.wantType, test.wantMessage)
+		}
+		if !bytes.HasPrefix(written.written.Bytes(), test.wantWritten) {
+			t.Errorf("%s: wrote %x, want it to start with %x", test.name, written.written.Bytes(), test.wantWritten)
+		}
+	}
+}
+
+func TestReadMessageClose(t *testing.T) {
+	conn, written := testConn([]byte{0x88, 0x82, 0, 0, 0, 0, 0x03, 0xe8}, false)
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadMessage of a close frame returned error %v, want io.EOF", err)
+	}
+	if want := []byte{0x88, 0x02, 0x03, 0xe8}; !bytes.Equal(written.written.Bytes(), want) {
+		t.Errorf("close frame echoed as %x, want %x", written.written.Bytes(), want)
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []byte
+		client bool
+	}{
+		{name: "unmasked frame from a client", frames: []byte{0x81, 0x02, 'h', 'i'}, client: false},
+		{name: "masked frame from a server", frames: []byte{0x81, 0x82, 0, 0, 0, 0, 'h', 'i'}, client: true},
+		{name: "extension bits", frames: []byte{0xc1, 0x02, 'h', 'i'}, client: true},
+		{name: "long control frame", frames: append([]byte{0x89, 0x7e, 0x00, 0x7e}, make([]byte, 126)...), client: true},
+		{name: "fragmented control frame", frames: []byte{0x09, 0x00}, client: true},
+		{name: "continuation without a message", frames: []byte{0x80, 0x02, 'h', 'i'}, client: true},
+		{name: "new message before the last finished", frames: []byte{0x01, 0x01, 'h', 0x81, 0x01, 'i'}, client: true},
+		{name: "unknown opcode", frames: []byte{0x83, 0x00}, client: true},
+		{name: "too large", frames: append([]byte{0x82, 0x7e, 0x04, 0x00}, make([]byte, 1024)...), client: true},
+		{name: "truncated", frames: []byte{0x82, 0x05, 'h', 'i'}, client: true},
+	}
+
+	for _, test := range tests {
+		conn, _ := testConn(test.frames, test.client)
+		conn.MaxMessageSize = 512
+		if _, _, err := conn.ReadMessage(); err == nil {
+			t.Errorf("%s: ReadMessage returned no error", test.name)
+		}
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	tests := []struct {
+		size       int
+		wantHeader []byte
+	}{
+		{size: 0, wantHeader: []byte{0x82, 0x00}},
+		{size: 125, wantHeader: []byte{0x82, 0x7d}},
+		{size: 126, wantHeader: []byte{0x82, 0x7e, 0x00, 0x7e}},
+		{size: 65535, wantHeader: []byte{0x82, 0x7e, 0xff, 0xff}},
+		{size: 65536, wantHeader: []byte{0x82, 0x7f, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00}},
+	}
+
+	for _, test := range tests {
+		data := bytes.Repeat([]byte{'x'}, test.size)
+
+		// frames written by a server are not masked
+		server, written := testConn(nil, false)
+		if err := server.WriteMessage(BINARY_MESSAGE, data); err != nil {
+			t.Fatal(err)
+		}
+		if want := append(test.wantHeader, data...); !bytes.Equal(written.written.Bytes(), want) {
+			t.Errorf("%d byte message written as %x, want %x", test.size, written.written.Bytes()[:len(test.wantHeader)], test.wantHeader)
+		}
+
+		// frames written by a client are masked and read back unchanged
+		client, written := testConn(nil, true)
+		if err := client.WriteMessage(BINARY_MESSAGE, data); err != nil {
+			t.Fatal(err)
+		}
+		reader, _ := testConn(written.written.Bytes(), false)
+		messageType, message, err := reader.ReadMessage()
+		if err != nil || messageType != BINARY_MESSAGE || !bytes.Equal(message, data) {
+			t.Errorf("%d byte message from a client read back as %d, %d bytes, %v", test.size, messageType, len(message), err)
+		}
+	}
+
+	conn, _ := testConn(nil, false)
+	if err := conn.WriteMessage(pingFrame, nil); err == nil {
+		t.Errorf("WriteMessage of a ping returned no error")
+	}
+}
+
+func TestDialAndUpgrade(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, func(r *http.Request) bool { return r.Header.Get("Origin") == "" })
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, bytes.ToUpper(message))
+		}
+	}))
+	defer server.Close()
+
+	conn, err := Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/path", nil)
+	if err != nil {
+		t.Fatalf("Dial returned error %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(TEXT_MESSAGE, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	messageType, message, err := conn.ReadMessage()
+	if err != nil || messageType != TEXT_MESSAGE || string(message) != "HELLO" {
+		t.Errorf("ReadMessage = %d, %q, %v, want the message echoed in upper case", messageType, message, err)
+	}
+
+	// NetConn sends each write as a message and reads messages as a stream
+	netConn := conn.NetConn()
+	if _, err := netConn.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 2)
+	var received []byte
+	for len(received) < 3 {
+		n, err := netConn.Read(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, buffer[:n]...)
+	}
+	if string(received) != "ABC" {
+		t.Errorf("NetConn read %q, want %q", received, "ABC")
+	}
+}
+
+func TestUpgradeErrors(t *testing.T) {
+	handshake := http.Header{}
+	handshake.Set("Upgrade", "websocket")
+	handshake.Set("Connection", "keep-alive, Upgrade")
+	handshake.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	handshake.Set("Sec-WebSocket-Version", "13")
+
+	tests := []struct {
+		name       string
+		method     string
+		change     func(header http.Header)
+		wantStatus int
+	}{
+		{name: "POST", method: http.MethodPost, change: func(header http.Header) {}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no upgrade", method: http.MethodGet, change: func(header http.Header) { header.Del("Upgrade") }, wantStatus: http.StatusBadRequest},
+		{name: "old version", method: http.MethodGet, change: func(header http.Header) { header.Set("Sec-WebSocket-Version", "8") }, wantStatus: http.StatusUpgradeRequired},
+		{name: "invalid key", method: http.MethodGet, change: func(header http.Header) { header.Set("Sec-WebSocket-Key", "short") }, wantStatus: http.StatusBadRequest},
+		{name: "origin", method: http.MethodGet, change: func(header http.Header) { header.Set("Origin", "http://example.com") }, wantStatus: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, "/", nil)
+		request.Header = handshake.Clone()
+		test.change(request.Header)
+		recorder := httptest.NewRecorder()
+
+		_, err := Upgrade(recorder, request, func(r *http.Request) bool { return r.Header.Get("Origin") == "" })
+		if err == nil || recorder.Code != test.wantStatus {
+			t.Errorf("%s: Upgrade returned status %d and error %v, want status %d", test.name, recorder.Code, err, test.wantStatus)
+		}
+	}
+}
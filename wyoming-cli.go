@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"os"
 
@@ -9,18 +8,7 @@ import (
 )
 
 func main() {
-	var err error
-
-	switch os.Args[1] {
-	case "tts":
-		err = commands.TTS()
-	case "asr":
-		err = commands.ASR()
-	default:
-		err = errors.New("unknown command")
-	}
-
-	if err != nil {
+	if err := commands.Run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
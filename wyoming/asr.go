@@ -1,10 +1,10 @@
 package wyoming
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
-	"os"
 	"sync"
 	"time"
 
@@ -35,6 +35,11 @@ type Transcription struct {
 	Text  string
 	Start time.Duration
 	End   time.Duration
+	// Split and Continued are copied from the utils.AudioEvent that was transcribed.
+	Split     bool
+	Continued bool
+	// Channel is the index of the audio channel that was transcribed when channels are transcribed separately.
+	Channel int
 }
 
 // ASRSupported returns true if ASR is supported by a Wyoming server.
@@ -74,50 +79,81 @@ func (w *WyomingConnection) TranscribeAudio(reader io.Reader, audioData WyomingA
 	return transcriptionData.Text, nil
 }
 
-func transcribeAudioGroupsWorker(audioData WyomingAudioData, serverAddr, modelName, language string, audioEventChan <-chan utils.AudioEvent, resultsChan chan<- Transcription, errorChan chan<- error, wg *sync.WaitGroup) {
+// sendError sends err to errorsChan unless ctx is done first.
+func sendError(ctx context.Context, errorsChan chan<- error, err error) {
+	select {
+	case errorsChan <- err:
+	case <-ctx.Done():
+	}
+}
+
+// sendTranscription sends transcription to resultsChan and returns false if ctx is done first.
+func sendTranscription(ctx context.Context, resultsChan chan<- Transcription, transcription Transcription) bool {
+	select {
+	case resultsChan <- transcription:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func transcribeAudioGroupsWorker(ctx context.Context, audioData WyomingAudioData, serverAddr string, connectOptions ConnectOptions, modelName, language string, audioEventChan <-chan utils.AudioEvent, resultsChan chan<- Transcription, errorChan chan<- error, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for audioEvent := range audioEventChan {
-		w, err := Connect(serverAddr)
+		if ctx.Err() != nil {
+			continue
+		}
+
+		w, err := ConnectWithOptions(serverAddr, connectOptions)
 		if err != nil {
-			errorChan <- err
+			sendError(ctx, errorChan, err)
 			return
 		}
 
 		defer w.Disconnect()
 		text, err := w.TranscribeAudio(&audioEvent.SoundBuff, audioData, modelName, language)
 		if err != nil {
-			errorChan <- err
+			sendError(ctx, errorChan, err)
 			return
 		}
-		resultsChan <- Transcription{Text: text, Start: audioEvent.Start, End: audioEvent.End}
+		sendTranscription(ctx, resultsChan, Transcription{Text: text, Start: audioEvent.Start, End: audioEvent.End, Split: audioEvent.Split, Continued: audioEvent.Continued})
 	}
 }
 
-// TranscribeAudioGroups transcribes the audio data from reader and sends the results, containing the
+// TranscribeAudioSegments transcribes the audio data from reader and sends the results, containing the
 // transcriptions with the start and end times, to resultsChan as they are generated. Errors are sent to errorsChan.
-// "workersCount" defines the number of transcription requests that are running at once. TranscribeAudioGroups
-// closes resultsChan and returns once an error occurs when reading from reader.
-func TranscribeAudioGroups(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration int, soundThreshold, silenceThreshold int32, resultsChan chan<- Transcription, errorsChan chan<- error) {
+// "workersCount" defines the number of transcription requests that are running at once, which is one for "stdio://"
+// servers, and "segmenter" splits the audio into the segments that are transcribed. TranscribeAudioSegments closes resultsChan and returns once
+// an error occurs when reading from reader.
+func TranscribeAudioSegments(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount int, segmenter utils.AudioSegmenter, resultsChan chan<- Transcription, errorsChan chan<- error) {
+	transcribeAudioSegments(context.Background(), reader, audioData, serverAddr, ConnectOptions{}, modelName, language, workersCount, segmenter, resultsChan, errorsChan)
+}
+
+// transcribeAudioSegments is TranscribeAudioSegments but connects using connectOptions and stops sending to
+// resultsChan and errorsChan and stops reading from reader once ctx is done, so that the caller can stop receiving
+// early.
+func transcribeAudioSegments(ctx context.Context, reader io.Reader, audioData WyomingAudioData, serverAddr string, connectOptions ConnectOptions, modelName, language string, workersCount int, segmenter utils.AudioSegmenter, resultsChan chan<- Transcription, errorsChan chan<- error) {
 	audioEventChan := make(chan utils.AudioEvent, workersCount)
 	wg := sync.WaitGroup{}
 
-	for i := 0; i < workersCount; i += 1 {
+	for i := 0; i < ConnectionWorkers(serverAddr, workersCount); i += 1 {
 		wg.Add(1)
-		go transcribeAudioGroupsWorker(audioData, serverAddr, modelName, language, audioEventChan, resultsChan, errorsChan, &wg)
+		go transcribeAudioGroupsWorker(ctx, audioData, serverAddr, connectOptions, modelName, language, audioEventChan, resultsChan, errorsChan, &wg)
 	}
 
-	currentTimeOffsetMS := 0
-	for {
-		audioEvent, err := utils.DetectNextAudioGroup16Bit(reader, audioData.Rate, audioData.Channels, audioWindowMS, currentTimeOffsetMS, soundThreshold, silenceThreshold, minSoundDuration, minSilenceDuration)
+	for reading := true; reading; {
+		audioEvent, err := segmenter.NextAudioGroup(reader)
 		if err != nil {
-			errorsChan <- err
+			sendError(ctx, errorsChan, err)
 			break
 		}
 
-		currentTimeOffsetMS = int(audioEvent.End.Milliseconds()) + minSilenceDuration
-
-		audioEventChan <- audioEvent
+		select {
+		case audioEventChan <- audioEvent:
+		case <-ctx.Done():
+			reading = false
+		}
 	}
 
 	close(audioEventChan)
@@ -126,16 +162,143 @@ func TranscribeAudioGroups(reader io.Reader, audioData WyomingAudioData, serverA
 	return
 }
 
-// TranscribeAllAudioGroups transcribes the audio data from reader and returns a slice
+// countingReader counts the bytes read from Reader.
+type countingReader struct {
+	Reader io.Reader
+	Count  int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.Count += n
+	return n, err
+}
+
+// TranscribeWholeAudio streams all of the audio data from reader to the Wyoming server at serverAddr on a single
+// connection without segmenting it and returns the transcription of the whole recording.
+func TranscribeWholeAudio(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string) (Transcription, error) {
+	return transcribeWholeAudio(reader, audioData, serverAddr, ConnectOptions{}, modelName, language)
+}
+
+// transcribeWholeAudio is TranscribeWholeAudio but connects using connectOptions.
+func transcribeWholeAudio(reader io.Reader, audioData WyomingAudioData, serverAddr string, connectOptions ConnectOptions, modelName, language string) (Transcription, error) {
+	w, err := ConnectWithOptions(serverAddr, connectOptions)
+	if err != nil {
+		return Transcription{}, err
+	}
+	defer w.Disconnect()
+
+	counter := countingReader{Reader: reader}
+	text, err := w.TranscribeAudio(&counter, audioData, modelName, language)
+	if err != nil {
+		return Transcription{}, err
+	}
+
+	bytesPerSecond := float64(audioData.Rate * audioData.Width * audioData.Channels)
+	return Transcription{
+		Text: text,
+		End:  time.Duration(float64(counter.Count) / bytesPerSecond * float64(time.Second)),
+	}, nil
+}
+
+// TranscribeWholeAudioFromFile transcribes the audio data from a WAV file located at filePath without segmenting
+// it. If chunkMS is greater than 0 and the audio is longer than chunkMS, it is split into chunks of chunkMS that
+// overlap by overlapMS and "workerCount" chunks are transcribed at once. Otherwise the whole file is streamed to
+// the server on a single connection.
+func TranscribeWholeAudioFromFile(filePath, modelName, language, serverAddr string, chunkMS, overlapMS, workerCount int) ([]Transcription, error) {
+	return transcribeWholeAudioFromFile(filePath, modelName, language, serverAddr, ConnectOptions{}, chunkMS, overlapMS, workerCount)
+}
+
+// transcribeWholeAudioFromFile is TranscribeWholeAudioFromFile but connects using connectOptions.
+func transcribeWholeAudioFromFile(filePath, modelName, language, serverAddr string, connectOptions ConnectOptions, chunkMS, overlapMS, workerCount int) ([]Transcription, error) {
+	WAVFile, audioData, err := OpenWAVFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer WAVFile.Close()
+
+	if chunkMS > 0 {
+		fileInfo, err := WAVFile.Stat()
+		if err != nil {
+			return nil, err
+		}
+		dataOffset, err := WAVFile.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+
+		bytesPerMS := float64(audioData.Rate*audioData.Width*audioData.Channels) / 1000
+		if float64(fileInfo.Size()-dataOffset)/bytesPerMS > float64(chunkMS) {
+			segmenter := &utils.ChunkSegmenter{Rate: audioData.Rate, Channels: audioData.Channels, ChunkMS: chunkMS, OverlapMS: overlapMS}
+			return transcribeAllAudioSegments(WAVFile, audioData, serverAddr, connectOptions, modelName, language, workerCount, segmenter)
+		}
+	}
+
+	transcription, err := transcribeWholeAudio(WAVFile, audioData, serverAddr, connectOptions, modelName, language)
+	if err != nil {
+		return nil, err
+	}
+	return []Transcription{transcription}, nil
+}
+
+// TranscribeAudioGroups transcribes the audio data from reader and sends the results, containing the
+// transcriptions with the start and end times, to resultsChan as they are generated. Errors are sent to errorsChan.
+// "workersCount" defines the number of transcription requests that are running at once. TranscribeAudioGroups
+// closes resultsChan and returns once an error occurs when reading from reader.
+//
+// Deprecated: use TranscribeStream, which supports every voice activity detector and segmenter option.
+func TranscribeAudioGroups(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration int, soundThreshold, silenceThreshold int32, resultsChan chan<- Transcription, errorsChan chan<- error) {
+	detector := &utils.PeakDetector{SoundThreshold: soundThreshold, SilenceThreshold: silenceThreshold}
+	TranscribeAudioGroupsWithDetector(reader, audioData, serverAddr, modelName, language, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration, detector, resultsChan, errorsChan)
+}
+
+// TranscribeAudioGroupsWithDetector is like TranscribeAudioGroups but segments the audio with "detector", which
+// should not be shared with other streams.
+//
+// Deprecated: use TranscribeStream, which supports every voice activity detector and segmenter option.
+func TranscribeAudioGroupsWithDetector(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration int, detector utils.VoiceActivityDetector, resultsChan chan<- Transcription, errorsChan chan<- error) {
+	segmenter := groupsSegmenter(audioData, audioWindowMS, minSoundDuration, minSilenceDuration, detector)
+	TranscribeAudioSegments(reader, audioData, serverAddr, modelName, language, workersCount, segmenter, resultsChan, errorsChan)
+}
+
+// groupsSegmenter returns the segmenter used by the TranscribeAudioGroups functions, which splits the audio at
+// the sound and silence found by detector without pre-roll, post-roll or a maximum segment length.
+func groupsSegmenter(audioData WyomingAudioData, audioWindowMS, minSoundDuration, minSilenceDuration int, detector utils.VoiceActivityDetector) *utils.Segmenter {
+	return &utils.Segmenter{
+		Detector:             detector,
+		Rate:                 audioData.Rate,
+		Channels:             audioData.Channels,
+		AudioWindowMS:        audioWindowMS,
+		MinSoundDurationMS:   minSoundDuration,
+		MinSilenceDurationMS: minSilenceDuration,
+	}
+}
+
+// TranscribeAllAudioSegments transcribes the audio data from reader and returns a slice
 // containing the transcriptions with the start and end times. "workersCount" defines
 // the number of transcription requests that are running at once. EOF and ErrUnexpectedEOF
 // errors are ignored.
-func TranscribeAllAudioGroups(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration int, soundThreshold, silenceThreshold int32) ([]Transcription, error) {
+func TranscribeAllAudioSegments(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount int, segmenter utils.AudioSegmenter) ([]Transcription, error) {
+	return transcribeAllAudioSegments(reader, audioData, serverAddr, ConnectOptions{}, modelName, language, workersCount, segmenter)
+}
+
+// transcribeAllAudioSegments is TranscribeAllAudioSegments but connects using connectOptions.
+func transcribeAllAudioSegments(reader io.Reader, audioData WyomingAudioData, serverAddr string, connectOptions ConnectOptions, modelName, language string, workersCount int, segmenter utils.AudioSegmenter) ([]Transcription, error) {
 	resultsChan := make(chan Transcription)
 	errorsChan := make(chan error)
-	var transcriptions []Transcription
 
-	go TranscribeAudioGroups(reader, audioData, serverAddr, modelName, language, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration, soundThreshold, silenceThreshold, resultsChan, errorsChan)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go transcribeAudioSegments(ctx, reader, audioData, serverAddr, connectOptions, modelName, language, workersCount, segmenter, resultsChan, errorsChan)
+
+	return collectTranscriptions(resultsChan, errorsChan)
+}
+
+// collectTranscriptions returns the transcriptions sent to resultsChan once it is closed. EOF and ErrUnexpectedEOF
+// errors sent to errorsChan are ignored. Callers cancel the context of the transcription once it returns so that
+// the senders do not block after an error.
+func collectTranscriptions(resultsChan <-chan Transcription, errorsChan <-chan error) ([]Transcription, error) {
+	var transcriptions []Transcription
 
 	for {
 		select {
@@ -153,32 +316,151 @@ func TranscribeAllAudioGroups(reader io.Reader, audioData WyomingAudioData, serv
 	}
 }
 
-// TranscribeAllAudioGroupsFromFile transcribes the audio data from a WAV file located at filePath and returns a slice
-// containing the transcriptions with the start and end times.
-func TranscribeAllAudioGroupsFromFile(filePath, modelName, language, serverAddr string, audioWindowMS, minSoundDuration, minSilenceDuration, workerCount int, soundThreshold, silenceThreshold int32) ([]Transcription, error) {
-	WAVFile, err := os.Open(filePath)
+// TranscribeAudioChannels transcribes each channel of the audio data from reader separately and sends the results
+// to resultsChan as they are generated, with Channel set to the index of the channel. Errors are sent to errorsChan.
+// "workersCount" defines the number of transcription requests that are running at once for each channel, so
+// "stdio://" servers run one process per channel, and
+// "newSegmenter" is called to create the segmenter for each channel. TranscribeAudioChannels closes resultsChan and
+// returns once every channel has finished.
+func TranscribeAudioChannels(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount int, newSegmenter func(audioData WyomingAudioData) utils.AudioSegmenter, resultsChan chan<- Transcription, errorsChan chan<- error) {
+	transcribeAudioChannels(context.Background(), reader, audioData, serverAddr, ConnectOptions{}, modelName, language, workersCount, newSegmenter, resultsChan, errorsChan)
+}
+
+// transcribeAudioChannels is TranscribeAudioChannels but connects using connectOptions and stops once ctx is done.
+// The channel readers are closed so that splitting the channels stops too.
+func transcribeAudioChannels(ctx context.Context, reader io.Reader, audioData WyomingAudioData, serverAddr string, connectOptions ConnectOptions, modelName, language string, workersCount int, newSegmenter func(audioData WyomingAudioData) utils.AudioSegmenter, resultsChan chan<- Transcription, errorsChan chan<- error) {
+	defer close(resultsChan)
+
+	channelReaders, err := utils.SplitChannels16Bits(reader, audioData.Channels)
+	if err != nil {
+		sendError(ctx, errorsChan, err)
+		return
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			for _, channelReader := range channelReaders {
+				channelReader.CloseWithError(ctx.Err())
+			}
+		case <-finished:
+		}
+	}()
+
+	channelAudioData := audioData
+	channelAudioData.Channels = 1
+
+	wg := sync.WaitGroup{}
+	for channel, channelReader := range channelReaders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			channelResultsChan := make(chan Transcription)
+			go transcribeAudioSegments(ctx, channelReader, channelAudioData, serverAddr, connectOptions, modelName, language, workersCount, newSegmenter(channelAudioData), channelResultsChan, errorsChan)
+
+			for result := range channelResultsChan {
+				result.Channel = channel
+				sendTranscription(ctx, resultsChan, result)
+			}
+
+			// keep reading so that the other channels are not blocked
+			io.Copy(io.Discard, channelReader)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// TranscribeAllAudioChannels transcribes each channel of the audio data from reader separately and returns a slice
+// containing the transcriptions of every channel with the start and end times. EOF and ErrUnexpectedEOF errors
+// are ignored.
+func TranscribeAllAudioChannels(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount int, newSegmenter func(audioData WyomingAudioData) utils.AudioSegmenter) ([]Transcription, error) {
+	resultsChan := make(chan Transcription)
+	errorsChan := make(chan error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go transcribeAudioChannels(ctx, reader, audioData, serverAddr, ConnectOptions{}, modelName, language, workersCount, newSegmenter, resultsChan, errorsChan)
+
+	return collectTranscriptions(resultsChan, errorsChan)
+}
+
+// TranscribeAllAudioChannelsFromFile transcribes each channel of the audio data from a WAV file located at filePath
+// separately and returns a slice containing the transcriptions of every channel with the start and end times.
+func TranscribeAllAudioChannelsFromFile(filePath, modelName, language, serverAddr string, workerCount int, newSegmenter func(audioData WyomingAudioData) utils.AudioSegmenter) ([]Transcription, error) {
+	WAVFile, audioData, err := OpenWAVFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer WAVFile.Close()
 
-	rate, channels, bitsPerSample, PCMAudioByteOffset, err := utils.ReadAudioInfoFromWAVFile(WAVFile)
+	transcriptions, err := TranscribeAllAudioChannels(WAVFile, audioData, serverAddr, modelName, language, workerCount, newSegmenter)
 	if err != nil {
 		return nil, err
 	}
+	return transcriptions, nil
+}
+
+// TranscribeAllAudioGroups transcribes the audio data from reader and returns a slice
+// containing the transcriptions with the start and end times. "workersCount" defines
+// the number of transcription requests that are running at once. EOF and ErrUnexpectedEOF
+// errors are ignored.
+//
+// Deprecated: use Transcribe, which supports every voice activity detector and segmenter option.
+func TranscribeAllAudioGroups(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration int, soundThreshold, silenceThreshold int32) ([]Transcription, error) {
+	detector := &utils.PeakDetector{SoundThreshold: soundThreshold, SilenceThreshold: silenceThreshold}
+	return TranscribeAllAudioGroupsWithDetector(reader, audioData, serverAddr, modelName, language, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration, detector)
+}
 
-	var width int = int(bitsPerSample / 8)
+// TranscribeAllAudioGroupsWithDetector is like TranscribeAllAudioGroups but segments the audio with "detector".
+//
+// Deprecated: use Transcribe, which supports every voice activity detector and segmenter option.
+func TranscribeAllAudioGroupsWithDetector(reader io.Reader, audioData WyomingAudioData, serverAddr, modelName, language string, workersCount, audioWindowMS, minSoundDuration, minSilenceDuration int, detector utils.VoiceActivityDetector) ([]Transcription, error) {
+	segmenter := groupsSegmenter(audioData, audioWindowMS, minSoundDuration, minSilenceDuration, detector)
+	return TranscribeAllAudioSegments(reader, audioData, serverAddr, modelName, language, workersCount, segmenter)
+}
+
+// TranscribeAllAudioSegmentsFromFile transcribes the audio data from a WAV file located at filePath and returns a slice
+// containing the transcriptions with the start and end times. "newSegmenter" is called with the format of the
+// audio in the file to create the segmenter used to split it.
+func TranscribeAllAudioSegmentsFromFile(filePath, modelName, language, serverAddr string, workerCount int, newSegmenter func(audioData WyomingAudioData) utils.AudioSegmenter) ([]Transcription, error) {
+	WAVFile, audioData, err := OpenWAVFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer WAVFile.Close()
 
-	if width != 2 {
-		return nil, errors.New("only 16-bit audio is supported")
+	transcriptions, err := TranscribeAllAudioSegments(WAVFile, audioData, serverAddr, modelName, language, workerCount, newSegmenter(audioData))
+	if err != nil {
+		return nil, err
 	}
+	return transcriptions, nil
+}
+
+// TranscribeAllAudioGroupsFromFile transcribes the audio data from a WAV file located at filePath and returns a slice
+// containing the transcriptions with the start and end times.
+//
+// Deprecated: use TranscribeFile, which supports every voice activity detector and segmenter option.
+func TranscribeAllAudioGroupsFromFile(filePath, modelName, language, serverAddr string, audioWindowMS, minSoundDuration, minSilenceDuration, workerCount int, soundThreshold, silenceThreshold int32) ([]Transcription, error) {
+	detector := &utils.PeakDetector{SoundThreshold: soundThreshold, SilenceThreshold: silenceThreshold}
+	return TranscribeAllAudioGroupsWithDetectorFromFile(filePath, modelName, language, serverAddr, audioWindowMS, minSoundDuration, minSilenceDuration, workerCount, detector)
+}
 
-	_, err = WAVFile.Seek(PCMAudioByteOffset, io.SeekStart)
+// TranscribeAllAudioGroupsWithDetectorFromFile is like TranscribeAllAudioGroupsFromFile but segments the audio with
+// "detector".
+//
+// Deprecated: use TranscribeFile, which supports every voice activity detector and segmenter option.
+func TranscribeAllAudioGroupsWithDetectorFromFile(filePath, modelName, language, serverAddr string, audioWindowMS, minSoundDuration, minSilenceDuration, workerCount int, detector utils.VoiceActivityDetector) ([]Transcription, error) {
+	WAVFile, audioData, err := OpenWAVFile(filePath)
 	if err != nil {
 		return nil, err
 	}
+	defer WAVFile.Close()
 
-	transcriptions, err := TranscribeAllAudioGroups(WAVFile, WyomingAudioData{Rate: int(rate), Channels: int(channels), Width: width}, serverAddr, modelName, language, workerCount, audioWindowMS, minSoundDuration, minSilenceDuration, soundThreshold, silenceThreshold)
+	transcriptions, err := TranscribeAllAudioGroupsWithDetector(WAVFile, audioData, serverAddr, modelName, language, workerCount, audioWindowMS, minSoundDuration, minSilenceDuration, detector)
 	if err != nil {
 		return nil, err
 	}
@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"os"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
 )
 
 var AudioStartMessageType string = "audio-start"
@@ -19,12 +22,22 @@ type WyomingAudioData struct {
 	Timestamp string `json:"timestamp,omitempty"`
 }
 
+// AudioFormatWriter is an io.Writer that needs to know the format of the audio before the audio is written,
+// such as a writer adding a WAV header.
+type AudioFormatWriter interface {
+	io.Writer
+	// WriteAudioFormat is called once before the first audio data is written.
+	WriteAudioFormat(audioData WyomingAudioData) error
+}
+
 // ReceiveAudio writes audio data to writer from "audio-chunk" messages as they are received. ReceiveAudio
 // stops listening for data once an "audio-stop" message is sent. ReceiveAudio returns a WyomingAudioData describing
-// the audio data or an error.
+// the audio data or an error. If writer is an AudioFormatWriter, it is told the audio format before the first audio
+// data is written.
 func (w *WyomingConnection) ReceiveAudio(writer io.Writer) (WyomingAudioData, error) {
 	var audioData WyomingAudioData
 	reader := bufio.NewReader(w.Conn)
+	formatWriter, needsFormat := writer.(AudioFormatWriter)
 
 	for {
 		res, err := w.ReceiveMessageUsingReader(reader)
@@ -40,6 +53,13 @@ func (w *WyomingConnection) ReceiveAudio(writer io.Writer) (WyomingAudioData, er
 				}
 			}
 
+			if needsFormat && len(res.Payload) > 0 {
+				if err := formatWriter.WriteAudioFormat(audioData); err != nil {
+					return WyomingAudioData{}, err
+				}
+				needsFormat = false
+			}
+
 			if len(res.Payload) > 0 {
 				err = binary.Write(writer, binary.LittleEndian, res.Payload)
 				if err != nil {
@@ -70,18 +90,21 @@ func (w *WyomingConnection) SendAudio(reader io.Reader, audioData WyomingAudioDa
 	}
 
 	for {
-		_, err := reader.Read(buf)
+		n, err := reader.Read(buf)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
 			return err
 		}
+		if n == 0 {
+			continue
+		}
 
 		err = w.SendMessageContainer(
 			WyomingMessageContainer{
 				Message: WyomingMessage{Type: AudioChunkMessageType, Data: audioData},
-				Payload: buf,
+				Payload: buf[:n],
 			},
 		)
 		if err != nil {
@@ -104,3 +127,33 @@ func (w *WyomingConnection) SendAudio(reader io.Reader, audioData WyomingAudioDa
 
 	return nil
 }
+
+// OpenWAVFile opens the 16-bit WAV file located at filePath and returns it positioned at the start of the PCM
+// audio data along with a WyomingAudioData describing the audio. The caller is responsible for closing the file.
+func OpenWAVFile(filePath string) (*os.File, WyomingAudioData, error) {
+	WAVFile, err := os.Open(filePath)
+	if err != nil {
+		return nil, WyomingAudioData{}, err
+	}
+
+	rate, channels, bitsPerSample, PCMAudioByteOffset, err := utils.ReadAudioInfoFromWAVFile(WAVFile)
+	if err != nil {
+		WAVFile.Close()
+		return nil, WyomingAudioData{}, err
+	}
+
+	var width int = int(bitsPerSample / 8)
+
+	if width != 2 {
+		WAVFile.Close()
+		return nil, WyomingAudioData{}, errors.New("only 16-bit audio is supported")
+	}
+
+	_, err = WAVFile.Seek(PCMAudioByteOffset, io.SeekStart)
+	if err != nil {
+		WAVFile.Close()
+		return nil, WyomingAudioData{}, err
+	}
+
+	return WAVFile, WyomingAudioData{Rate: int(rate), Channels: int(channels), Width: width}, nil
+}
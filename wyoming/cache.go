@@ -0,0 +1,210 @@
+package wyoming
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TTSCache stores synthesized audio on disk so that repeated requests do not need to be synthesized again. Each
+// entry is a "<key>.pcm" file with the audio and a "<key>.json" file with its WyomingAudioData. Once the entries
+// take up more than MaxBytes the least recently used ones are removed.
+type TTSCache struct {
+	Dir      string
+	MaxBytes int64
+
+	lock sync.Mutex
+}
+
+// NewTTSCache returns a TTSCache storing entries in dir, creating dir if needed. maxBytes of 0 or less disables
+// the size limit.
+func NewTTSCache(dir string, maxBytes int64) (*TTSCache, error) {
+	if dir == "" {
+		return nil, errors.New("missing cache directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &TTSCache{Dir: dir, MaxBytes: maxBytes}, nil
+}
+
+// TTSCacheKey returns the key of the audio for text spoken by voiceData on the server at serverAddr.
+func TTSCacheKey(serverAddr, text string, voiceData SynthesizeVoiceData) string {
+	keyData, _ := json.Marshal(struct {
+		ServerAddr string              `json:"server"`
+		Text       string              `json:"text"`
+		Voice      SynthesizeVoiceData `json:"voice"`
+	}{serverAddr, text, voiceData})
+
+	hash := sha256.Sum256(keyData)
+	return hex.EncodeToString(hash[:])
+}
+
+func (c *TTSCache) audioPath(key string) string {
+	return filepath.Join(c.Dir, key+".pcm")
+}
+
+func (c *TTSCache) metadataPath(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// Get writes the cached audio for key to writer and returns its WyomingAudioData. The returned bool is false if
+// key is not in the cache. If writer is an AudioFormatWriter, it is told the audio format first.
+func (c *TTSCache) Get(key string, writer io.Writer) (WyomingAudioData, bool, error) {
+	metadata, err := os.ReadFile(c.metadataPath(key))
+	if err != nil {
+		return WyomingAudioData{}, false, nil
+	}
+
+	var audioData WyomingAudioData
+	if err := json.Unmarshal(metadata, &audioData); err != nil {
+		return WyomingAudioData{}, false, nil
+	}
+
+	audioFile, err := os.Open(c.audioPath(key))
+	if err != nil {
+		return WyomingAudioData{}, false, nil
+	}
+	defer audioFile.Close()
+
+	if formatWriter, ok := writer.(AudioFormatWriter); ok {
+		if err := formatWriter.WriteAudioFormat(audioData); err != nil {
+			return WyomingAudioData{}, false, err
+		}
+	}
+
+	if _, err := io.Copy(writer, audioFile); err != nil {
+		return WyomingAudioData{}, false, err
+	}
+
+	// the modification time tracks when an entry was last used
+	now := time.Now()
+	os.Chtimes(c.audioPath(key), now, now)
+
+	return audioData, true, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the cache directory before renaming it to filePath so that
+// readers never see a partial file.
+func (c *TTSCache) writeFileAtomic(filePath string, data []byte) error {
+	tempFile, err := os.CreateTemp(c.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), filePath)
+}
+
+// Put stores audio described by audioData under key and removes the least recently used entries once the cache
+// is larger than MaxBytes. Audio larger than MaxBytes is not stored.
+func (c *TTSCache) Put(key string, audioData WyomingAudioData, audio []byte) error {
+	if c.MaxBytes > 0 && int64(len(audio)) > c.MaxBytes {
+		return nil
+	}
+
+	metadata, err := json.Marshal(audioData)
+	if err != nil {
+		return err
+	}
+
+	// the metadata is written last, entries without it are ignored
+	if err := c.writeFileAtomic(c.audioPath(key), audio); err != nil {
+		return err
+	}
+	if err := c.writeFileAtomic(c.metadataPath(key), metadata); err != nil {
+		return err
+	}
+
+	return c.evict()
+}
+
+// evict removes the least recently used entries until the cache is no larger than MaxBytes.
+func (c *TTSCache) evict() error {
+	if c.MaxBytes <= 0 {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	type entry struct {
+		key     string
+		size    int64
+		lastUse time.Time
+	}
+
+	dirEntries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+
+	var entries []entry
+	var totalSize int64
+	for _, dirEntry := range dirEntries {
+		key, isAudio := strings.CutSuffix(dirEntry.Name(), ".pcm")
+		if !isAudio || dirEntry.IsDir() {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, entry{key: key, size: info.Size(), lastUse: info.ModTime()})
+		totalSize += info.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].lastUse.Before(entries[j].lastUse) })
+
+	for _, e := range entries {
+		if totalSize <= c.MaxBytes {
+			break
+		}
+
+		os.Remove(c.metadataPath(e.key))
+		if err := os.Remove(c.audioPath(e.key)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		totalSize -= e.size
+	}
+
+	return nil
+}
+
+// cacheWriter passes writes on to Writer while keeping a copy of the audio for the cache.
+type cacheWriter struct {
+	Writer io.Writer
+	Audio  bytes.Buffer
+}
+
+func (c *cacheWriter) Write(p []byte) (int, error) {
+	c.Audio.Write(p)
+	return c.Writer.Write(p)
+}
+
+// WriteAudioFormat implements AudioFormatWriter for writers that need the audio format.
+func (c *cacheWriter) WriteAudioFormat(audioData WyomingAudioData) error {
+	if formatWriter, ok := c.Writer.(AudioFormatWriter); ok {
+		return formatWriter.WriteAudioFormat(audioData)
+	}
+	return nil
+}
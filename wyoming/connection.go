@@ -2,8 +2,8 @@ package wyoming
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
-	"fmt"
 	"net"
 )
 
@@ -23,10 +23,21 @@ type WyomingMessageContainer struct {
 	Payload []byte
 }
 
+// ConnectOptions configures how connections to a Wyoming server are made. The zero value uses the defaults.
+type ConnectOptions struct {
+	// TLSConfig is used by the "tcps://" and "wss://" transports. The server name defaults to the host in the
+	// server address.
+	TLSConfig *tls.Config
+}
+
 type WyomingConnection struct {
-	Conn          net.Conn
-	ServerAddr    string
-	VoiceServices WyomingVoiceServicesData
+	Conn       net.Conn
+	ServerAddr string
+	// ConnectOptions are the options the connection was made with, which Reconnect uses again.
+	ConnectOptions ConnectOptions
+	VoiceServices  WyomingVoiceServicesData
+	// TTSCache is used by SynthesizeAudio when set.
+	TTSCache *TTSCache
 }
 
 type WyomingAttribution struct {
@@ -69,21 +80,12 @@ func (w *WyomingConnection) GetAvailableServices() (WyomingVoiceServicesData, er
 
 // SendMessage sends a message to a Wyoming server followed by a newline character.
 func (w *WyomingConnection) SendMessage(msg WyomingMessage) error {
-	jsonMessage, err := json.Marshal(msg)
-	if err != nil {
-		return err
-	}
-
-	_, err = fmt.Fprintf(w.Conn, "%s\n", string(jsonMessage))
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return w.SendMessageContainer(WyomingMessageContainer{Message: msg})
 }
 
 // SendMessageContainer sends Message, Data, and Payload from container to a Wyoming server. DataLength and PayloadLength
-// are set before sending.
+// are set before sending. The message is sent with a single write so that message based transports, such as
+// WebSockets, carry each Wyoming message in one piece.
 func (w *WyomingConnection) SendMessageContainer(container WyomingMessageContainer) error {
 	if container.Data != nil {
 		container.Message.DataLength = len(container.Data)
@@ -92,23 +94,20 @@ func (w *WyomingConnection) SendMessageContainer(container WyomingMessageContain
 		container.Message.PayloadLength = len(container.Payload)
 	}
 
-	err := w.SendMessage(container.Message)
+	jsonMessage, err := json.Marshal(container.Message)
 	if err != nil {
 		return err
 	}
 
-	if container.Message.DataLength > 0 {
-		_, err = w.Conn.Write(container.Data)
-		if err != nil {
-			return err
-		}
-	}
+	message := make([]byte, 0, len(jsonMessage)+1+len(container.Data)+len(container.Payload))
+	message = append(message, jsonMessage...)
+	message = append(message, '\n')
+	message = append(message, container.Data...)
+	message = append(message, container.Payload...)
 
-	if container.Message.PayloadLength > 0 {
-		_, err = w.Conn.Write(container.Payload)
-		if err != nil {
-			return err
-		}
+	_, err = w.Conn.Write(message)
+	if err != nil {
+		return err
 	}
 
 	return nil
@@ -174,7 +173,7 @@ func (w *WyomingConnection) Reconnect() error {
 	if err != nil {
 		return err
 	}
-	newConn, err := net.Dial("tcp", w.ServerAddr)
+	newConn, err := dial(w.ServerAddr, w.ConnectOptions)
 	if err != nil {
 		return err
 	}
@@ -183,16 +182,24 @@ func (w *WyomingConnection) Reconnect() error {
 	return nil
 }
 
-// Connect connects to a Wyoming server and checks supported features.
+// Connect connects to a Wyoming server and checks supported features. serverAddr is either a "host:port" address
+// for TCP or a URI whose scheme is in Transports, such as "unix:///run/piper.sock", "stdio://piper-server --voice
+// en_US-amy" or "ws://host:port/path".
 func Connect(serverAddr string) (WyomingConnection, error) {
-	conn, err := net.Dial("tcp", serverAddr)
+	return ConnectWithOptions(serverAddr, ConnectOptions{})
+}
+
+// ConnectWithOptions is like Connect but connects using options.
+func ConnectWithOptions(serverAddr string, options ConnectOptions) (WyomingConnection, error) {
+	conn, err := dial(serverAddr, options)
 	if err != nil {
 		return WyomingConnection{}, err
 	}
 
-	w := WyomingConnection{ServerAddr: serverAddr, Conn: conn}
+	w := WyomingConnection{ServerAddr: serverAddr, ConnectOptions: options, Conn: conn}
 	w.VoiceServices, err = w.GetAvailableServices()
 	if err != nil {
+		conn.Close()
 		return WyomingConnection{}, err
 	}
 
@@ -0,0 +1,439 @@
+package wyoming
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+// SegmenterOptions configures how audio is split into segments of speech before it is transcribed. Error messages
+// from Validate name the matching asr flags.
+type SegmenterOptions struct {
+	// VADAddr is the address of a Wyoming VAD server to use instead of VAD when set.
+	VADAddr string
+	// VAD is the local voice activity detector, one of utils.VAD_PEAK, utils.VAD_ENERGY or utils.VAD_SPECTRAL.
+	VAD           string
+	AudioWindowMS int
+	// SoundThreshold and SilenceThreshold are the peak levels of sound and silence for utils.VAD_PEAK.
+	SoundThreshold   int32
+	SilenceThreshold int32
+	// EnergySoundDB and EnergySilenceDB are the levels above the noise floor of sound and silence for
+	// utils.VAD_ENERGY and utils.VAD_SPECTRAL.
+	EnergySoundDB   float64
+	EnergySilenceDB float64
+	// SpectralFlatness is the highest spectral flatness of sound for utils.VAD_SPECTRAL.
+	SpectralFlatness     float64
+	MinSoundDurationMS   int
+	MinSilenceDurationMS int
+	PreRollMS            int
+	PostRollMS           int
+	// MaxSegmentMS splits longer segments at their quietest point. 0 means no limit.
+	MaxSegmentMS int
+	// ConnectOptions are used to connect to VADAddr.
+	ConnectOptions ConnectOptions
+}
+
+// DefaultSegmenterOptions returns the SegmenterOptions used by the asr command when no flags are given.
+func DefaultSegmenterOptions() SegmenterOptions {
+	return SegmenterOptions{
+		VAD:                  utils.VAD_PEAK,
+		AudioWindowMS:        100,
+		SoundThreshold:       20000,
+		SilenceThreshold:     2000,
+		EnergySoundDB:        12,
+		EnergySilenceDB:      6,
+		SpectralFlatness:     0.4,
+		MinSoundDurationMS:   100,
+		MinSilenceDurationMS: 100,
+	}
+}
+
+// Validate returns an error if the options cannot be used to segment audio.
+func (o SegmenterOptions) Validate() error {
+	if o.AudioWindowMS <= 0 {
+		return errors.New("audio-window-ms must be greater than 0")
+	}
+	switch o.VAD {
+	case utils.VAD_PEAK:
+		if o.SoundThreshold <= 0 {
+			return errors.New("sound-threshold must be greater than 0")
+		}
+		if o.SilenceThreshold <= 0 {
+			return errors.New("silence-threshold must be greater than 0")
+		}
+	case utils.VAD_ENERGY, utils.VAD_SPECTRAL:
+		if o.VAD == utils.VAD_SPECTRAL && (o.SpectralFlatness <= 0 || o.SpectralFlatness > 1) {
+			return errors.New("spectral-flatness must be greater than 0 and at most 1")
+		}
+		if o.EnergySoundDB <= 0 {
+			return errors.New("energy-sound-db must be greater than 0")
+		}
+		if o.EnergySilenceDB <= 0 {
+			return errors.New("energy-silence-db must be greater than 0")
+		}
+		if o.EnergySilenceDB > o.EnergySoundDB {
+			return errors.New("energy-silence-db must not be greater than energy-sound-db")
+		}
+	default:
+		return errors.New("vad must be one of: " + utils.VAD_PEAK + ", " + utils.VAD_ENERGY + ", " + utils.VAD_SPECTRAL)
+	}
+	if o.MinSoundDurationMS <= 0 {
+		return errors.New("min-sound-duration-ms must be greater than 0")
+	}
+	if o.MinSoundDurationMS%o.AudioWindowMS != 0 {
+		return errors.New("min-sound-duration-ms must be divisible by audio-window-ms")
+	}
+	if o.MinSilenceDurationMS <= 0 {
+		return errors.New("min-silence-duration-ms must be greater than 0")
+	}
+	if o.MinSilenceDurationMS%o.AudioWindowMS != 0 {
+		return errors.New("min-silence-duration-ms must be divisible by audio-window-ms")
+	}
+	if o.PreRollMS < 0 {
+		return errors.New("pre-roll-ms must not be negative")
+	}
+	if o.PreRollMS%o.AudioWindowMS != 0 {
+		return errors.New("pre-roll-ms must be divisible by audio-window-ms")
+	}
+	if o.PostRollMS < 0 {
+		return errors.New("post-roll-ms must not be negative")
+	}
+	if o.PostRollMS%o.AudioWindowMS != 0 {
+		return errors.New("post-roll-ms must be divisible by audio-window-ms")
+	}
+	if o.PostRollMS > o.MinSilenceDurationMS {
+		return errors.New("post-roll-ms must not be greater than min-silence-duration-ms")
+	}
+	if o.MaxSegmentMS < 0 {
+		return errors.New("max-segment-ms must not be negative")
+	}
+	if o.MaxSegmentMS%o.AudioWindowMS != 0 {
+		return errors.New("max-segment-ms must be divisible by audio-window-ms")
+	}
+	if o.MaxSegmentMS > 0 && o.MaxSegmentMS <= o.MinSoundDurationMS {
+		return errors.New("max-segment-ms must be greater than min-sound-duration-ms")
+	}
+
+	return nil
+}
+
+// NewDetector returns a new voice activity detector selected by VAD.
+func (o SegmenterOptions) NewDetector() utils.VoiceActivityDetector {
+	switch o.VAD {
+	case utils.VAD_ENERGY:
+		return utils.NewEnergyDetector(o.EnergySoundDB, o.EnergySilenceDB)
+	case utils.VAD_SPECTRAL:
+		return utils.NewSpectralDetector(o.EnergySoundDB, o.EnergySilenceDB, o.SpectralFlatness)
+	default:
+		return &utils.PeakDetector{SoundThreshold: o.SoundThreshold, SilenceThreshold: o.SilenceThreshold}
+	}
+}
+
+// NewSegmenter returns a new segmenter for a stream of audio described by audioData. If VADAddr is set, the
+// Wyoming VAD service at VADAddr is used instead of a local detector.
+func (o SegmenterOptions) NewSegmenter(audioData WyomingAudioData) utils.AudioSegmenter {
+	if o.VADAddr != "" {
+		segmenter := NewRemoteVADSegmenter(o.VADAddr, audioData, o.AudioWindowMS)
+		segmenter.ConnectOptions = o.ConnectOptions
+		segmenter.MinSoundDurationMS = o.MinSoundDurationMS
+		segmenter.MinSilenceDurationMS = o.MinSilenceDurationMS
+		segmenter.PreRollMS = o.PreRollMS
+		segmenter.PostRollMS = o.PostRollMS
+		segmenter.MaxSegmentMS = o.MaxSegmentMS
+		return segmenter
+	}
+
+	return &utils.Segmenter{
+		Detector:             o.NewDetector(),
+		Rate:                 audioData.Rate,
+		Channels:             audioData.Channels,
+		AudioWindowMS:        o.AudioWindowMS,
+		MinSoundDurationMS:   o.MinSoundDurationMS,
+		MinSilenceDurationMS: o.MinSilenceDurationMS,
+		PreRollMS:            o.PreRollMS,
+		PostRollMS:           o.PostRollMS,
+		MaxSegmentMS:         o.MaxSegmentMS,
+	}
+}
+
+// TranscribeOptions configures a transcription. Error messages from Validate name the matching asr flags.
+type TranscribeOptions struct {
+	ServerAddr string
+	// ConnectOptions are used to connect to ServerAddr. Segmenter.ConnectOptions are used for Segmenter.VADAddr.
+	ConnectOptions ConnectOptions
+	ModelName      string
+	Language       string
+	// WorkersCount is the number of transcription requests running at once.
+	WorkersCount int
+	// Whole transcribes the audio without detecting sound. Audio longer than ChunkMS is split into chunks of
+	// ChunkMS overlapping by ChunkOverlapMS. A ChunkMS of 0 streams the whole audio on one connection.
+	Whole          bool
+	ChunkMS        int
+	ChunkOverlapMS int
+	// SplitChannels transcribes each channel separately.
+	SplitChannels bool
+	// Segmenter configures how audio is split into segments of speech unless NewSegmenter is set.
+	Segmenter    SegmenterOptions
+	NewSegmenter func(audioData WyomingAudioData) utils.AudioSegmenter
+}
+
+// DefaultTranscribeOptions returns the TranscribeOptions used by the asr command when no flags are given.
+func DefaultTranscribeOptions() TranscribeOptions {
+	return TranscribeOptions{
+		ServerAddr:     "localhost:10300",
+		WorkersCount:   3,
+		ChunkOverlapMS: 1000,
+		Segmenter:      DefaultSegmenterOptions(),
+	}
+}
+
+// Validate returns an error if the options cannot be used to transcribe audio.
+func (o TranscribeOptions) Validate() error {
+	if o.ServerAddr == "" {
+		return errors.New("missing server address")
+	}
+	if o.WorkersCount <= 0 {
+		return errors.New("num-workers must be greater than 0")
+	}
+	if o.Whole && o.SplitChannels {
+		return errors.New("whole cannot be used with split-channels")
+	}
+	if o.ChunkMS < 0 {
+		return errors.New("chunk-ms must not be negative")
+	}
+	if o.ChunkOverlapMS < 0 {
+		return errors.New("chunk-overlap-ms must not be negative")
+	}
+	if o.ChunkMS > 0 && o.ChunkOverlapMS >= o.ChunkMS {
+		return errors.New("chunk-overlap-ms must be less than chunk-ms")
+	}
+	if !o.Whole && o.NewSegmenter == nil {
+		return o.Segmenter.Validate()
+	}
+
+	return nil
+}
+
+// newSegmenter returns NewSegmenter if it is set and otherwise a function creating segmenters from Segmenter.
+func (o TranscribeOptions) newSegmenter() func(audioData WyomingAudioData) utils.AudioSegmenter {
+	if o.NewSegmenter != nil {
+		return o.NewSegmenter
+	}
+	return o.Segmenter.NewSegmenter
+}
+
+// TranscribeStream transcribes the audio data from reader as it is read and sends each segment's transcription to
+// resultsChan as it is generated. Errors are sent to errorsChan. With Whole, each chunk's transcription is sent
+// without merging the overlaps, which OverlapMerger can do as they arrive. TranscribeStream closes resultsChan and
+// returns once the audio has been transcribed.
+func TranscribeStream(reader io.Reader, audioData WyomingAudioData, options TranscribeOptions, resultsChan chan<- Transcription, errorsChan chan<- error) {
+	TranscribeStreamContext(context.Background(), reader, audioData, options, resultsChan, errorsChan)
+}
+
+// TranscribeStreamContext is like TranscribeStream but stops reading from reader and sending to resultsChan and
+// errorsChan once ctx is done. Callers that stop receiving before resultsChan is closed must cancel ctx.
+func TranscribeStreamContext(ctx context.Context, reader io.Reader, audioData WyomingAudioData, options TranscribeOptions, resultsChan chan<- Transcription, errorsChan chan<- error) {
+	if err := options.Validate(); err != nil {
+		sendError(ctx, errorsChan, err)
+		close(resultsChan)
+		return
+	}
+
+	if options.Whole {
+		if options.ChunkMS > 0 {
+			segmenter := &utils.ChunkSegmenter{Rate: audioData.Rate, Channels: audioData.Channels, ChunkMS: options.ChunkMS, OverlapMS: options.ChunkOverlapMS}
+			transcribeAudioSegments(ctx, reader, audioData, options.ServerAddr, options.ConnectOptions, options.ModelName, options.Language, options.WorkersCount, segmenter, resultsChan, errorsChan)
+			return
+		}
+
+		transcription, err := transcribeWholeAudio(reader, audioData, options.ServerAddr, options.ConnectOptions, options.ModelName, options.Language)
+		if err != nil {
+			sendError(ctx, errorsChan, err)
+		} else {
+			sendTranscription(ctx, resultsChan, transcription)
+		}
+		close(resultsChan)
+		return
+	}
+
+	if options.SplitChannels {
+		transcribeAudioChannels(ctx, reader, audioData, options.ServerAddr, options.ConnectOptions, options.ModelName, options.Language, options.WorkersCount, options.newSegmenter(), resultsChan, errorsChan)
+		return
+	}
+	transcribeAudioSegments(ctx, reader, audioData, options.ServerAddr, options.ConnectOptions, options.ModelName, options.Language, options.WorkersCount, options.newSegmenter()(audioData), resultsChan, errorsChan)
+}
+
+// Transcribe transcribes all of the audio data from reader and returns the transcriptions with their start and end
+// times. Overlapping chunks of Whole transcriptions are merged and segments split by MaxSegmentMS are joined.
+func Transcribe(reader io.Reader, audioData WyomingAudioData, options TranscribeOptions) ([]Transcription, error) {
+	resultsChan := make(chan Transcription)
+	errorsChan := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go TranscribeStreamContext(ctx, reader, audioData, options, resultsChan, errorsChan)
+
+	transcriptions, err := collectTranscriptions(resultsChan, errorsChan)
+	if err != nil {
+		return nil, err
+	}
+	if options.Whole {
+		transcriptions = MergeOverlappingTranscriptions(transcriptions)
+	}
+	return JoinSplitTranscriptions(transcriptions), nil
+}
+
+// TranscribeFile transcribes the WAV file located at filePath like Transcribe. Whole files no longer than ChunkMS
+// are streamed on a single connection.
+func TranscribeFile(filePath string, options TranscribeOptions) ([]Transcription, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	if options.Whole {
+		transcriptions, err := transcribeWholeAudioFromFile(filePath, options.ModelName, options.Language, options.ServerAddr, options.ConnectOptions, options.ChunkMS, options.ChunkOverlapMS, options.WorkersCount)
+		if err != nil {
+			return nil, err
+		}
+		return JoinSplitTranscriptions(MergeOverlappingTranscriptions(transcriptions)), nil
+	}
+
+	WAVFile, audioData, err := OpenWAVFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer WAVFile.Close()
+
+	return Transcribe(WAVFile, audioData, options)
+}
+
+// SynthesizeOptions configures a synthesis. Error messages from Validate name the matching tts flags.
+type SynthesizeOptions struct {
+	ServerAddr string
+	// ConnectOptions are used to connect to ServerAddr.
+	ConnectOptions ConnectOptions
+	Voice          SynthesizeVoiceData
+	// Split synthesizes long text in chunks of at most MaxChars, split at sentence boundaries, and joins them with
+	// SentenceSilenceMS or ParagraphSilenceMS of silence.
+	Split    bool
+	MaxChars int
+	// SSML reads the text as SSML. SentenceSilenceMS and ParagraphSilenceMS are the breaks after <s> and <p>.
+	SSML               bool
+	SentenceSilenceMS  int
+	ParagraphSilenceMS int
+	// WorkersCount is the number of text chunks synthesized at once with Split or SSML.
+	WorkersCount int
+	// Cache is used when set. With Split or SSML each chunk is cached on its own.
+	Cache *TTSCache
+}
+
+// DefaultSynthesizeOptions returns the SynthesizeOptions used by the tts command when no flags are given.
+func DefaultSynthesizeOptions() SynthesizeOptions {
+	return SynthesizeOptions{
+		ServerAddr:         "localhost:10200",
+		MaxChars:           400,
+		SentenceSilenceMS:  300,
+		ParagraphSilenceMS: 800,
+		WorkersCount:       3,
+	}
+}
+
+// Validate returns an error if the options cannot be used to synthesize audio.
+func (o SynthesizeOptions) Validate() error {
+	if o.ServerAddr == "" {
+		return errors.New("missing server address")
+	}
+	if o.WorkersCount <= 0 {
+		return errors.New("num-workers must be greater than 0")
+	}
+	if o.Split || o.SSML {
+		if o.MaxChars < 0 {
+			return errors.New("max-chars must not be negative")
+		}
+		if o.SentenceSilenceMS < 0 || o.ParagraphSilenceMS < 0 {
+			return errors.New("sentence-silence-ms and paragraph-silence-ms must not be negative")
+		}
+	}
+	if o.Split && o.SSML {
+		return errors.New("split cannot be used with ssml")
+	}
+
+	return nil
+}
+
+// Synthesize checks that the voices used exist on the server and writes the audio for text to writer as it is
+// received. Audio found in options.Cache for text synthesized in one request is written without connecting to the
+// server.
+func Synthesize(text string, options SynthesizeOptions, writer io.Writer) (WyomingAudioData, error) {
+	if err := options.Validate(); err != nil {
+		return WyomingAudioData{}, err
+	}
+
+	// cached audio for text synthesized in one request does not need the server
+	if options.Cache != nil && !options.Split && !options.SSML {
+		audioData, found, err := options.Cache.Get(TTSCacheKey(options.ServerAddr, text, options.Voice), writer)
+		if err != nil || found {
+			return audioData, err
+		}
+	}
+
+	w, err := ConnectWithOptions(options.ServerAddr, options.ConnectOptions)
+	if err != nil {
+		return WyomingAudioData{}, err
+	}
+	w.TTSCache = options.Cache
+
+	pieces, err := validatedSpeechPieces(&w, text, options)
+	if err != nil {
+		w.Disconnect()
+		return WyomingAudioData{}, err
+	}
+	if pieces == nil {
+		defer w.Disconnect()
+		return w.SynthesizeAudio(text, options.Voice, writer)
+	}
+
+	// the pieces are synthesized on connections of their own
+	w.Disconnect()
+	return synthesizePieces(options.ServerAddr, options.ConnectOptions, pieces, options.WorkersCount, options.Cache, writer)
+}
+
+// validatedSpeechPieces checks the voices used to synthesize text with w and returns the pieces text is split into
+// with Split or SSML, or nil when it is synthesized in one request.
+func validatedSpeechPieces(w *WyomingConnection, text string, options SynthesizeOptions) ([]speechPiece, error) {
+	if err := w.ValidateVoice(options.Voice); err != nil {
+		return nil, err
+	}
+
+	if options.SSML {
+		segments, err := utils.ParseSSML(text, options.SentenceSilenceMS, options.ParagraphSilenceMS)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range segments {
+			if segment.Text == "" {
+				continue
+			}
+			if err := w.ValidateVoice(SSMLVoiceData(segment, options.Voice)); err != nil {
+				return nil, err
+			}
+		}
+
+		return ssmlPieces(segments, options.Voice), nil
+	}
+
+	if options.Split {
+		return longTextPieces(text, options.Voice, options.MaxChars, options.SentenceSilenceMS, options.ParagraphSilenceMS), nil
+	}
+
+	return nil, nil
+}
+
+// SynthesizeToWAVFile synthesizes text like Synthesize and creates a new WAV audio file located at WAVFilePath
+// with the audio.
+func SynthesizeToWAVFile(text string, options SynthesizeOptions, WAVFilePath string) error {
+	return synthesizeToWAVFile(WAVFilePath, func(writer io.Writer) (WyomingAudioData, error) {
+		return Synthesize(text, options, writer)
+	})
+}
@@ -0,0 +1,45 @@
+package wyoming
+
+import (
+	"io"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+// SSMLVoiceData returns the voice options used for segment. The voice and language of the segment replace the
+// ones in voiceData. The speaker is only kept when the voice is not changed.
+func SSMLVoiceData(segment utils.SSMLSegment, voiceData SynthesizeVoiceData) SynthesizeVoiceData {
+	if segment.Voice != "" && segment.Voice != voiceData.Name {
+		voiceData.Name = segment.Voice
+		voiceData.Speaker = ""
+	}
+	if segment.Language != "" {
+		voiceData.Language = segment.Language
+	}
+	return voiceData
+}
+
+// SynthesizeSSML synthesizes the segments parsed from SSML with "workersCount" requests at once and writes the
+// audio to writer in order with the silence between segments. voiceData is used for segments that do not set
+// their own voice. SynthesizeSSML returns a WyomingAudioData describing the audio data or an error.
+func SynthesizeSSML(serverAddr string, segments []utils.SSMLSegment, voiceData SynthesizeVoiceData, workersCount int, writer io.Writer) (WyomingAudioData, error) {
+	return synthesizePieces(serverAddr, ConnectOptions{}, ssmlPieces(segments, voiceData), workersCount, nil, writer)
+}
+
+// ssmlPieces returns the pieces synthesized by SynthesizeSSML for segments.
+func ssmlPieces(segments []utils.SSMLSegment, voiceData SynthesizeVoiceData) []speechPiece {
+	pieces := make([]speechPiece, len(segments))
+	for i, segment := range segments {
+		pieces[i] = speechPiece{Text: segment.Text, VoiceData: SSMLVoiceData(segment, voiceData), SilenceBeforeMS: segment.BreakBeforeMS}
+	}
+
+	return pieces
+}
+
+// SynthesizeSSMLToWAVFile synthesizes segments using SynthesizeSSML and creates a new WAV audio file located at
+// WAVFilePath with the audio received.
+func SynthesizeSSMLToWAVFile(serverAddr string, segments []utils.SSMLSegment, voiceData SynthesizeVoiceData, workersCount int, WAVFilePath string) error {
+	return synthesizeToWAVFile(WAVFilePath, func(writer io.Writer) (WyomingAudioData, error) {
+		return SynthesizeSSML(serverAddr, segments, voiceData, workersCount, writer)
+	})
+}
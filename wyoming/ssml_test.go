@@ -0,0 +1,63 @@
+package wyoming
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+func TestSSMLVoiceData(t *testing.T) {
+	voiceData := SynthesizeVoiceData{Name: "en_US-amy", Speaker: "a", Language: "en_US"}
+
+	tests := []struct {
+		segment utils.SSMLSegment
+		want    SynthesizeVoiceData
+	}{
+		{segment: utils.SSMLSegment{Text: "Hi"}, want: voiceData},
+		{segment: utils.SSMLSegment{Text: "Hi", Voice: "en_US-amy"}, want: voiceData},
+		{segment: utils.SSMLSegment{Text: "Hallo", Voice: "de_DE-thorsten"}, want: SynthesizeVoiceData{Name: "de_DE-thorsten", Language: "en_US"}},
+		{segment: utils.SSMLSegment{Text: "Hello", Language: "en_GB"}, want: SynthesizeVoiceData{Name: "en_US-amy", Speaker: "a", Language: "en_GB"}},
+	}
+
+	for _, test := range tests {
+		got := SSMLVoiceData(test.segment, voiceData)
+		if got != test.want {
+			t.Errorf("SSMLVoiceData(%+v) = %+v, want %+v", test.segment, got, test.want)
+		}
+	}
+}
+
+func TestSynthesizeSSML(t *testing.T) {
+	useTestTTS(t)
+
+	tests := []struct {
+		ssml string
+		want string
+	}{
+		{ssml: `<speak>One <break time="50ms"/> two</speak>`, want: "One" + testSilence(50) + "two"},
+		{ssml: `<speak><break time="20ms"/>One<break time="30ms"/></speak>`, want: testSilence(20) + "One" + testSilence(30)},
+		{ssml: `<speak><p>One</p><p>Two</p></speak>`, want: "One" + testSilence(300) + "Two"},
+	}
+
+	for _, test := range tests {
+		segments, err := utils.ParseSSML(test.ssml, 100, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var audio bytes.Buffer
+		if _, err := SynthesizeSSML("ttstest://", segments, SynthesizeVoiceData{}, 2, &audio); err != nil {
+			t.Errorf("SynthesizeSSML(%q) returned error %v", test.ssml, err)
+			continue
+		}
+		if audio.String() != test.want {
+			t.Errorf("SynthesizeSSML(%q) wrote %q, want %q", test.ssml, audio.String(), test.want)
+		}
+	}
+
+	segments := []utils.SSMLSegment{{BreakBeforeMS: 100}}
+	if _, err := SynthesizeSSML("ttstest://", segments, SynthesizeVoiceData{}, 2, &bytes.Buffer{}); err == nil {
+		t.Errorf("SynthesizeSSML without text returned no error")
+	}
+}
@@ -0,0 +1,309 @@
+package wyoming
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// normalizeWord returns word in lower case without surrounding punctuation for comparing words.
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}))
+}
+
+// joinTexts joins the text of two transcriptions of adjacent audio. A word cut in half at the split may be
+// recognized at the end of first and at the start of second, so a repeated boundary word is only kept once.
+func joinTexts(first, second string) string {
+	firstWords := strings.Fields(first)
+	secondWords := strings.Fields(second)
+	if len(firstWords) == 0 {
+		return strings.Join(secondWords, " ")
+	}
+	if len(secondWords) == 0 {
+		return strings.Join(firstWords, " ")
+	}
+
+	if normalizeWord(firstWords[len(firstWords)-1]) == normalizeWord(secondWords[0]) {
+		firstWords = firstWords[:len(firstWords)-1]
+	}
+
+	return strings.Join(append(firstWords, secondWords...), " ")
+}
+
+// continues reports if next is the continuation of the split segment transcribed by previous.
+func continues(previous, next Transcription) bool {
+	return previous.Split && next.Continued && previous.Channel == next.Channel && previous.End == next.Start
+}
+
+// TranscriptionJoiner joins transcriptions of segments that were split at a maximum length as they arrive.
+// Transcriptions may be added in any order.
+type TranscriptionJoiner struct {
+	pending []Transcription
+}
+
+// Add adds transcription and returns any transcriptions that are complete, joined with the other parts of
+// their split segment and ordered by start time.
+func (j *TranscriptionJoiner) Add(transcription Transcription) []Transcription {
+	if !transcription.Split && !transcription.Continued {
+		return []Transcription{transcription}
+	}
+
+	j.pending = append(j.pending, transcription)
+	slices.SortFunc(j.pending, func(a, b Transcription) int {
+		return cmp.Or(cmp.Compare(a.Channel, b.Channel), cmp.Compare(a.Start, b.Start))
+	})
+
+	var complete []Transcription
+	for chainStart := 0; chainStart < len(j.pending); {
+		chainEnd := chainStart
+		for chainEnd < len(j.pending) && j.pending[chainEnd].Split {
+			if chainEnd+1 >= len(j.pending) || !continues(j.pending[chainEnd], j.pending[chainEnd+1]) {
+				break
+			}
+			chainEnd += 1
+		}
+
+		if j.pending[chainStart].Continued || j.pending[chainEnd].Split {
+			chainStart = chainEnd + 1
+			continue
+		}
+
+		joined := j.pending[chainStart]
+		for _, part := range j.pending[chainStart+1 : chainEnd+1] {
+			joined.Text = joinTexts(joined.Text, part.Text)
+			joined.End = part.End
+		}
+		joined.Split = false
+		complete = append(complete, joined)
+
+		j.pending = slices.Delete(j.pending, chainStart, chainEnd+1)
+	}
+
+	return complete
+}
+
+// Flush returns the transcriptions still waiting for the other parts of their split segment, joining the parts
+// that are available.
+func (j *TranscriptionJoiner) Flush() []Transcription {
+	var flushed []Transcription
+	for _, transcription := range j.pending {
+		if len(flushed) > 0 && continues(flushed[len(flushed)-1], transcription) {
+			last := &flushed[len(flushed)-1]
+			last.Text = joinTexts(last.Text, transcription.Text)
+			last.End = transcription.End
+			last.Split = transcription.Split
+			continue
+		}
+		flushed = append(flushed, transcription)
+	}
+	j.pending = nil
+
+	return flushed
+}
+
+// JoinSplitTranscriptions orders transcriptions by start time and joins the transcriptions of segments that were
+// split at a maximum length.
+func JoinSplitTranscriptions(transcriptions []Transcription) []Transcription {
+	joiner := TranscriptionJoiner{}
+
+	var joined []Transcription
+	for _, transcription := range transcriptions {
+		joined = append(joined, joiner.Add(transcription)...)
+	}
+	joined = append(joined, joiner.Flush()...)
+
+	slices.SortStableFunc(joined, func(a, b Transcription) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	return joined
+}
+
+// longestCommonWordSequence returns the start of the longest run of words shared by first and second along with its
+// length. Words are compared using normalizeWord.
+func longestCommonWordSequence(first, second []string) (int, int, int) {
+	bestFirstStart, bestSecondStart, bestLength := 0, 0, 0
+
+	// lengths[j+1] holds the length of the common run ending at first[i] and second[j]
+	lengths := make([]int, len(second)+1)
+	for i := range first {
+		previousDiagonal := 0
+		for j := range second {
+			current := lengths[j+1]
+			if normalizeWord(first[i]) == normalizeWord(second[j]) && normalizeWord(first[i]) != "" {
+				lengths[j+1] = previousDiagonal + 1
+				if lengths[j+1] > bestLength {
+					bestLength = lengths[j+1]
+					bestFirstStart = i - bestLength + 1
+					bestSecondStart = j - bestLength + 1
+				}
+			} else {
+				lengths[j+1] = 0
+			}
+			previousDiagonal = current
+		}
+	}
+
+	return bestFirstStart, bestSecondStart, bestLength
+}
+
+// findOverlap returns the start of the words heard by both first and second, found by aligning the end of first and
+// the start of second by their longest common sequence of words, along with its length. A single shared word is
+// only treated as an overlap when it is the last word of first and the first word of second. The length is 0 if no
+// overlap is found.
+func findOverlap(firstWords, secondWords []string) (int, int, int) {
+	// only the end of first and the start of second can contain the overlap
+	tailStart := len(firstWords) / 2
+	headEnd := len(secondWords) - len(secondWords)/2
+	firstStart, secondStart, length := longestCommonWordSequence(firstWords[tailStart:], secondWords[:headEnd])
+	firstStart += tailStart
+
+	if length == 1 && (firstStart != len(firstWords)-1 || secondStart != 0) {
+		length = 0
+	}
+
+	return firstStart, secondStart, length
+}
+
+// MergeOverlappingTexts joins the text of two transcriptions whose audio overlaps. The end of first and the start of
+// second are aligned by their longest common sequence of words and the words heard twice are only kept once. A
+// single shared word is only treated as a duplicate when it is the last word of first and the first word of second.
+// If no overlap is found the texts are joined with a space.
+func MergeOverlappingTexts(first, second string) string {
+	firstWords := strings.Fields(first)
+	secondWords := strings.Fields(second)
+
+	firstStart, secondStart, length := findOverlap(firstWords, secondWords)
+	if length == 0 {
+		return strings.Join(append(firstWords, secondWords...), " ")
+	}
+
+	merged := append([]string{}, firstWords[:firstStart+length]...)
+	merged = append(merged, secondWords[secondStart+length:]...)
+
+	return strings.Join(merged, " ")
+}
+
+// MergeOverlappingTranscriptions orders transcriptions by start time and merges each transcription with the next one
+// on the same channel when their audio overlaps, such as the chunks created by utils.ChunkSegmenter, removing the
+// words transcribed twice.
+func MergeOverlappingTranscriptions(transcriptions []Transcription) []Transcription {
+	sorted := slices.Clone(transcriptions)
+	slices.SortStableFunc(sorted, func(a, b Transcription) int {
+		return cmp.Or(cmp.Compare(a.Channel, b.Channel), cmp.Compare(a.Start, b.Start))
+	})
+
+	var merged []Transcription
+	for _, transcription := range sorted {
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			if last.Channel == transcription.Channel && transcription.Start < last.End {
+				last.Text = MergeOverlappingTexts(last.Text, transcription.Text)
+				last.End = max(last.End, transcription.End)
+				continue
+			}
+		}
+		merged = append(merged, transcription)
+	}
+
+	slices.SortStableFunc(merged, func(a, b Transcription) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	return merged
+}
+
+// OverlapMerger merges the transcriptions of overlapping chunks created by utils.ChunkSegmenter as they arrive,
+// removing the words transcribed twice like MergeOverlappingTranscriptions. Only the text of the latest chunk is
+// held back, since its end may still be replaced by the next chunk. Transcriptions may be added in any order.
+type OverlapMerger struct {
+	// Overlap is the length of audio shared by neighboring chunks.
+	Overlap time.Duration
+
+	pending   []Transcription
+	held      Transcription
+	holding   bool
+	nextStart time.Duration
+}
+
+// nextChunk removes and returns the pending transcription of the chunk following the one held.
+func (m *OverlapMerger) nextChunk() (Transcription, bool) {
+	for i, transcription := range m.pending {
+		// chunk times are converted from byte offsets so they may differ slightly
+		if (transcription.Start - m.nextStart).Abs() < time.Millisecond {
+			m.pending = slices.Delete(m.pending, i, i+1)
+			return transcription, true
+		}
+	}
+	return Transcription{}, false
+}
+
+// merge merges next into the held transcription and returns the text that can no longer change.
+func (m *OverlapMerger) merge(next Transcription) []Transcription {
+	m.nextStart = next.End - m.Overlap
+	if !m.holding {
+		m.held = next
+		m.holding = true
+		return nil
+	}
+
+	heldWords := strings.Fields(m.held.Text)
+	nextWords := strings.Fields(next.Text)
+	heldStart, nextStart, length := findOverlap(heldWords, nextWords)
+	if length == 0 {
+		heldStart, nextStart = len(heldWords), 0
+	}
+
+	complete := Transcription{Text: strings.Join(heldWords[:heldStart], " "), Start: m.held.Start, End: next.Start}
+	merged := append([]string{}, heldWords[heldStart:heldStart+length]...)
+	merged = append(merged, nextWords[nextStart+length:]...)
+	next.Text = strings.Join(merged, " ")
+	m.held = next
+
+	if complete.Text == "" {
+		return nil
+	}
+	return []Transcription{complete}
+}
+
+// Add adds transcription and returns the merged text that is complete, ordered by start time.
+func (m *OverlapMerger) Add(transcription Transcription) []Transcription {
+	m.pending = append(m.pending, transcription)
+
+	var complete []Transcription
+	for next, ok := m.nextChunk(); ok; next, ok = m.nextChunk() {
+		complete = append(complete, m.merge(next)...)
+	}
+	return complete
+}
+
+// Flush merges the remaining transcriptions, including any after a missing chunk, and returns the rest of the text.
+func (m *OverlapMerger) Flush() []Transcription {
+	slices.SortStableFunc(m.pending, func(a, b Transcription) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	var complete []Transcription
+	for _, transcription := range m.pending {
+		if m.holding && transcription.Start >= m.held.End {
+			// a chunk is missing so nothing overlaps
+			if m.held.Text != "" {
+				complete = append(complete, m.held)
+			}
+			m.holding = false
+		}
+		complete = append(complete, m.merge(transcription)...)
+	}
+	if m.holding && m.held.Text != "" {
+		complete = append(complete, m.held)
+	}
+
+	m.pending = nil
+	m.holding = false
+	m.nextStart = 0
+	return complete
+}
@@ -0,0 +1,215 @@
+package wyoming
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJoinTexts(t *testing.T) {
+	tests := []struct {
+		first  string
+		second string
+		want   string
+	}{
+		{first: "the quick brown", second: "fox jumps", want: "the quick brown fox jumps"},
+		{first: "the quick bro", second: "brown fox", want: "the quick bro brown fox"},
+		{first: "the quick brown", second: "Brown, fox", want: "the quick Brown, fox"},
+		{first: "", second: "fox  jumps", want: "fox jumps"},
+		{first: "the quick ", second: "", want: "the quick"},
+	}
+
+	for _, test := range tests {
+		got := joinTexts(test.first, test.second)
+		if got != test.want {
+			t.Errorf("joinTexts(%q, %q) = %q, want %q", test.first, test.second, got, test.want)
+		}
+	}
+}
+
+func TestJoinSplitTranscriptions(t *testing.T) {
+	s := time.Second
+
+	tests := []struct {
+		name           string
+		transcriptions []Transcription
+		want           []Transcription
+	}{
+		{
+			name:           "not split",
+			transcriptions: []Transcription{{Text: "two", Start: 3 * s, End: 4 * s}, {Text: "one", Start: 1 * s, End: 2 * s}},
+			want:           []Transcription{{Text: "one", Start: 1 * s, End: 2 * s}, {Text: "two", Start: 3 * s, End: 4 * s}},
+		},
+		{
+			name: "split in order",
+			transcriptions: []Transcription{
+				{Text: "one two", Start: 0, End: 1 * s, Split: true},
+				{Text: "two three", Start: 1 * s, End: 2 * s, Split: true, Continued: true},
+				{Text: "four", Start: 2 * s, End: 3 * s, Continued: true},
+			},
+			want: []Transcription{{Text: "one two three four", Start: 0, End: 3 * s}},
+		},
+		{
+			name: "split out of order",
+			transcriptions: []Transcription{
+				{Text: "four", Start: 2 * s, End: 3 * s, Continued: true},
+				{Text: "five", Start: 4 * s, End: 5 * s},
+				{Text: "one two", Start: 0, End: 1 * s, Split: true},
+				{Text: "three", Start: 1 * s, End: 2 * s, Split: true, Continued: true},
+			},
+			want: []Transcription{{Text: "one two three four", Start: 0, End: 3 * s}, {Text: "five", Start: 4 * s, End: 5 * s}},
+		},
+		{
+			name: "separate channels",
+			transcriptions: []Transcription{
+				{Text: "left", Start: 0, End: 1 * s, Split: true, Channel: 0},
+				{Text: "right", Start: 0, End: 1 * s, Split: true, Channel: 1},
+				{Text: "again", Start: 1 * s, End: 2 * s, Continued: true, Channel: 1},
+				{Text: "more", Start: 1 * s, End: 2 * s, Continued: true, Channel: 0},
+			},
+			// transcriptions starting at the same time stay in the order they were completed
+			want: []Transcription{{Text: "right again", Start: 0, End: 2 * s, Channel: 1}, {Text: "left more", Start: 0, End: 2 * s, Channel: 0}},
+		},
+		{
+			// the remaining parts are joined when the last part is missing
+			name: "missing part",
+			transcriptions: []Transcription{
+				{Text: "one", Start: 0, End: 1 * s, Split: true},
+				{Text: "two", Start: 1 * s, End: 2 * s, Split: true, Continued: true},
+			},
+			want: []Transcription{{Text: "one two", Start: 0, End: 2 * s, Split: true}},
+		},
+	}
+
+	for _, test := range tests {
+		got := JoinSplitTranscriptions(test.transcriptions)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: JoinSplitTranscriptions = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLongestCommonWordSequence(t *testing.T) {
+	tests := []struct {
+		first           []string
+		second          []string
+		wantFirstStart  int
+		wantSecondStart int
+		wantLength      int
+	}{
+		{first: []string{"a", "b", "c", "d"}, second: []string{"x", "b", "c", "y"}, wantFirstStart: 1, wantSecondStart: 1, wantLength: 2},
+		{first: []string{"a", "b"}, second: []string{"c", "d"}, wantFirstStart: 0, wantSecondStart: 0, wantLength: 0},
+		{first: []string{"Hello,", "world"}, second: []string{"hello", "World."}, wantFirstStart: 0, wantSecondStart: 0, wantLength: 2},
+		{first: []string{"a", "b", "x", "a", "b", "c"}, second: []string{"a", "b", "c"}, wantFirstStart: 3, wantSecondStart: 0, wantLength: 3},
+		{first: []string{"-", "-"}, second: []string{"-"}, wantFirstStart: 0, wantSecondStart: 0, wantLength: 0},
+		{first: nil, second: []string{"a"}, wantFirstStart: 0, wantSecondStart: 0, wantLength: 0},
+	}
+
+	for _, test := range tests {
+		firstStart, secondStart, length := longestCommonWordSequence(test.first, test.second)
+		if firstStart != test.wantFirstStart || secondStart != test.wantSecondStart || length != test.wantLength {
+			t.Errorf("longestCommonWordSequence(%q, %q) = %d, %d, %d, want %d, %d, %d", test.first, test.second, firstStart, secondStart, length, test.wantFirstStart, test.wantSecondStart, test.wantLength)
+		}
+	}
+}
+
+func TestMergeOverlappingTexts(t *testing.T) {
+	tests := []struct {
+		first  string
+		second string
+		want   string
+	}{
+		{first: "the quick brown fox jumps", second: "brown fox jumps over the lazy dog", want: "the quick brown fox jumps over the lazy dog"},
+		{first: "one two three four fiv", second: "three four five six", want: "one two three four five six"},
+		{first: "hello there", second: "general kenobi", want: "hello there general kenobi"},
+		{first: "I went to the", second: "the store", want: "I went to the store"},
+		{first: "we saw a cat today", second: "a dog barked", want: "we saw a cat today a dog barked"},
+		{first: "", second: "a b", want: "a b"},
+		{first: "a b", second: "", want: "a b"},
+	}
+
+	for _, test := range tests {
+		got := MergeOverlappingTexts(test.first, test.second)
+		if got != test.want {
+			t.Errorf("MergeOverlappingTexts(%q, %q) = %q, want %q", test.first, test.second, got, test.want)
+		}
+	}
+}
+
+func TestMergeOverlappingTranscriptions(t *testing.T) {
+	s := time.Second
+	transcriptions := []Transcription{
+		{Text: "five six", Start: 20 * s, End: 25 * s},
+		{Text: "three four five", Start: 8 * s, End: 18 * s},
+		{Text: "one two three four", Start: 0, End: 10 * s},
+		{Text: "left", Start: 9 * s, End: 12 * s, Channel: 1},
+	}
+	want := []Transcription{
+		{Text: "one two three four five", Start: 0, End: 18 * s},
+		{Text: "left", Start: 9 * s, End: 12 * s, Channel: 1},
+		{Text: "five six", Start: 20 * s, End: 25 * s},
+	}
+
+	got := MergeOverlappingTranscriptions(transcriptions)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeOverlappingTranscriptions = %+v, want %+v", got, want)
+	}
+}
+
+func TestOverlapMerger(t *testing.T) {
+	s := time.Second
+	chunks := []Transcription{
+		{Text: "one two three four", Start: 0, End: 10 * s},
+		{Text: "three four five six", Start: 8 * s, End: 18 * s},
+		{Text: "five six seven", Start: 16 * s, End: 26 * s},
+	}
+
+	tests := []struct {
+		name  string
+		order []int
+		want  [][]Transcription
+	}{
+		{
+			name:  "in order",
+			order: []int{0, 1, 2},
+			want: [][]Transcription{
+				nil,
+				{{Text: "one two", Start: 0, End: 8 * s}},
+				{{Text: "three four", Start: 8 * s, End: 16 * s}},
+				{{Text: "five six seven", Start: 16 * s, End: 26 * s}},
+			},
+		},
+		{
+			name:  "out of order",
+			order: []int{1, 0, 2},
+			want: [][]Transcription{
+				nil,
+				{{Text: "one two", Start: 0, End: 8 * s}},
+				{{Text: "three four", Start: 8 * s, End: 16 * s}},
+				{{Text: "five six seven", Start: 16 * s, End: 26 * s}},
+			},
+		},
+		{
+			name:  "missing chunk",
+			order: []int{0, 2},
+			want: [][]Transcription{
+				nil,
+				nil,
+				{chunks[0], chunks[2]},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		merger := OverlapMerger{Overlap: 2 * s}
+		var got [][]Transcription
+		for _, i := range test.order {
+			got = append(got, merger.Add(chunks[i]))
+		}
+		got = append(got, merger.Flush())
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: OverlapMerger returned %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
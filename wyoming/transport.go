@@ -0,0 +1,166 @@
+package wyoming
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+	"github.com/john-pettigrew/wyoming-cli/websocket"
+)
+
+// Transport opens a connection to the Wyoming server at serverAddr using options. The Wyoming messages are written to
+// and read from the returned connection unchanged.
+type Transport func(serverAddr string, options ConnectOptions) (net.Conn, error)
+
+// Transports maps URI schemes to the Transport used for server addresses starting with "<scheme>://". Addresses
+// without a scheme, such as "localhost:10200", use TCP.
+var Transports = map[string]Transport{
+	"tcp":   dialTCP,
+	"tcps":  dialTLS,
+	"unix":  dialUnix,
+	"stdio": dialStdio,
+	"ws":    dialWebSocket,
+	"wss":   dialWebSocket,
+}
+
+// dialTCP connects to a "tcp://host:port" address.
+func dialTCP(serverAddr string, options ConnectOptions) (net.Conn, error) {
+	return net.Dial("tcp", strings.TrimPrefix(serverAddr, "tcp://"))
+}
+
+// dialTLS connects to a "tcps://host:port" address using options.TLSConfig.
+func dialTLS(serverAddr string, options ConnectOptions) (net.Conn, error) {
+	return tls.Dial("tcp", strings.TrimPrefix(serverAddr, "tcps://"), options.TLSConfig)
+}
+
+// dialUnix connects to a "unix://<socket path>" address.
+func dialUnix(serverAddr string, options ConnectOptions) (net.Conn, error) {
+	socketPath := strings.TrimPrefix(serverAddr, "unix://")
+	if socketPath == "" {
+		return nil, errors.New("missing unix socket path")
+	}
+	return net.Dial("unix", socketPath)
+}
+
+// dialStdio starts the server command in a "stdio://<command> [args...]" address and talks to it over its standard
+// input and output. The command is split into arguments like a shell would, so paths with spaces can be quoted.
+// The command's standard error is passed through. Closing the connection stops the command, so every connection
+// starts a new server process.
+func dialStdio(serverAddr string, options ConnectOptions) (net.Conn, error) {
+	commandArgs, err := utils.SplitCommandLine(strings.TrimPrefix(serverAddr, "stdio://"))
+	if err != nil {
+		return nil, err
+	}
+	if len(commandArgs) == 0 {
+		return nil, errors.New("missing stdio server command")
+	}
+
+	cmd := exec.Command(commandArgs[0], commandArgs[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &stdioConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+// dialWebSocket connects to a Wyoming server behind a WebSocket endpoint, using options.TLSConfig for "wss://"
+// addresses. Each Wyoming message is sent as a single binary message.
+func dialWebSocket(serverAddr string, options ConnectOptions) (net.Conn, error) {
+	conn, err := websocket.Dial(serverAddr, options.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	return conn.NetConn(), nil
+}
+
+// ConnectionWorkers returns the number of connections to serverAddr to open at once for workersCount workers.
+// "stdio://" servers are limited to one, since each connection starts a new server process.
+func ConnectionWorkers(serverAddr string, workersCount int) int {
+	if strings.HasPrefix(serverAddr, "stdio://") {
+		return min(workersCount, 1)
+	}
+	return workersCount
+}
+
+// dial connects to serverAddr using the Transport for its scheme.
+func dial(serverAddr string, options ConnectOptions) (net.Conn, error) {
+	if scheme, _, found := strings.Cut(serverAddr, "://"); found {
+		if transport, ok := Transports[scheme]; ok {
+			return transport(serverAddr, options)
+		}
+	}
+
+	return net.Dial("tcp", serverAddr)
+}
+
+// stdioAddr is the net.Addr of a stdioConn.
+type stdioAddr string
+
+func (s stdioAddr) Network() string {
+	return "stdio"
+}
+
+func (s stdioAddr) String() string {
+	return string(s)
+}
+
+// stdioConn implements net.Conn on top of the standard input and output of a server command. Deadlines are not
+// supported.
+type stdioConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (s *stdioConn) Read(p []byte) (int, error) {
+	return s.stdout.Read(p)
+}
+
+func (s *stdioConn) Write(p []byte) (int, error) {
+	return s.stdin.Write(p)
+}
+
+// Close closes the command's standard input and stops the command.
+func (s *stdioConn) Close() error {
+	s.stdin.Close()
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	s.cmd.Wait()
+	return nil
+}
+
+func (s *stdioConn) LocalAddr() net.Addr {
+	return stdioAddr("stdio")
+}
+
+func (s *stdioConn) RemoteAddr() net.Addr {
+	return stdioAddr(s.cmd.String())
+}
+
+func (s *stdioConn) SetDeadline(t time.Time) error {
+	return errors.New("deadlines are not supported by stdio connections")
+}
+
+func (s *stdioConn) SetReadDeadline(t time.Time) error {
+	return s.SetDeadline(t)
+}
+
+func (s *stdioConn) SetWriteDeadline(t time.Time) error {
+	return s.SetDeadline(t)
+}
@@ -0,0 +1,75 @@
+package wyoming
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/john-pettigrew/wyoming-cli/websocket"
+)
+
+func TestConnectWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/wyoming" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := websocket.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		serveTestTTS(conn.NetConn())
+	}))
+	defer server.Close()
+	serverAddr := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	w, err := Connect(serverAddr + "/wyoming")
+	if err != nil {
+		t.Fatalf("Connect returned error %v", err)
+	}
+	defer w.Disconnect()
+	if !w.TTSSupported() {
+		t.Errorf("Connect returned services %+v, want TTS", w.VoiceServices)
+	}
+
+	// the second text is sent in frames with an extended length
+	for _, text := range []string{"Hello there.", strings.Repeat("long ", 600)} {
+		var audio bytes.Buffer
+		if _, err := w.SynthesizeAudio(text, SynthesizeVoiceData{}, &audio); err != nil {
+			t.Fatalf("SynthesizeAudio returned error %v", err)
+		}
+		if audio.String() != text {
+			t.Errorf("SynthesizeAudio over a WebSocket returned %d bytes, want %d", audio.Len(), len(text))
+		}
+	}
+
+	if _, err := Connect(serverAddr + "/missing"); err == nil {
+		t.Errorf("Connect to a missing WebSocket endpoint returned no error")
+	}
+}
+
+func TestDialScheme(t *testing.T) {
+	useTestTTS(t)
+
+	tests := []struct {
+		serverAddr string
+		wantErr    bool
+	}{
+		{serverAddr: "ttstest://anything", wantErr: false},
+		{serverAddr: "ftp://127.0.0.1:1", wantErr: true},
+		{serverAddr: "wss://", wantErr: true},
+		{serverAddr: "ws://[::1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		conn, err := dial(test.serverAddr, ConnectOptions{})
+		if (err != nil) != test.wantErr {
+			t.Errorf("dial(%q) returned error %v, want error %v", test.serverAddr, err, test.wantErr)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
@@ -21,14 +21,23 @@ type SynthesizeData struct {
 	Voice SynthesizeVoiceData `json:"voice,omitempty"`
 }
 
+// WyomingTTSVoice is a voice of a TTS service described by a Wyoming server.
+type WyomingTTSVoice struct {
+	Name        string             `json:"name"`
+	Attribution WyomingAttribution `json:"attribution"`
+	Languages   []string           `json:"languages,omitempty"`
+	Speakers    []struct {
+		Name string `json:"name,omitempty"`
+	} `json:"speakers,omitempty"`
+	Installed   bool   `json:"installed"`
+	Description string `json:"description,omitempty"`
+}
+
 type WyomingVoiceServicesTTSData struct {
-	Name      string   `json:"name"`
-	Languages []string `json:"languages"`
-	Voices    []struct {
-		Name        string             `json:"name"`
-		Attribution WyomingAttribution `json:"attribution"`
-	} `json:"voices"`
-	Speakers []struct {
+	Name      string            `json:"name"`
+	Languages []string          `json:"languages"`
+	Voices    []WyomingTTSVoice `json:"voices"`
+	Speakers  []struct {
 		Name string `json:"name,omitempty"`
 	} `json:"speakers"`
 	Attribution WyomingAttribution `json:"attribution"`
@@ -43,8 +52,33 @@ func (w *WyomingConnection) TTSSupported() bool {
 }
 
 // SynthesizeAudio sends a "synthesize" command with voiceData options to a Wyoming server and writes the audio response
-// to writer. SynthesizeAudio returns a WyomingAudioData describing the audio data or an error.
+// to writer. SynthesizeAudio returns a WyomingAudioData describing the audio data or an error. If w.TTSCache is set,
+// cached audio is written instead of sending the command and new audio is added to the cache.
 func (w *WyomingConnection) SynthesizeAudio(text string, voiceData SynthesizeVoiceData, writer io.Writer) (WyomingAudioData, error) {
+	if w.TTSCache == nil {
+		return w.synthesizeAudio(text, voiceData, writer)
+	}
+
+	key := TTSCacheKey(w.ServerAddr, text, voiceData)
+	audioData, found, err := w.TTSCache.Get(key, writer)
+	if err != nil || found {
+		return audioData, err
+	}
+
+	cacheWriter := cacheWriter{Writer: writer}
+	audioData, err = w.synthesizeAudio(text, voiceData, &cacheWriter)
+	if err != nil {
+		return WyomingAudioData{}, err
+	}
+
+	if err := w.TTSCache.Put(key, audioData, cacheWriter.Audio.Bytes()); err != nil {
+		return WyomingAudioData{}, err
+	}
+
+	return audioData, nil
+}
+
+func (w *WyomingConnection) synthesizeAudio(text string, voiceData SynthesizeVoiceData, writer io.Writer) (WyomingAudioData, error) {
 	if !w.TTSSupported() {
 		return WyomingAudioData{}, errors.New("server does not appear to support TTS")
 	}
@@ -0,0 +1,199 @@
+package wyoming
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+// speechPiece is a piece of text synthesized on its own, with SilenceBeforeMS of silence added before its audio.
+// A piece without text only adds silence.
+type speechPiece struct {
+	Text            string
+	VoiceData       SynthesizeVoiceData
+	SilenceBeforeMS int
+}
+
+// synthesizedPiece is the audio generated for the piece at Index.
+type synthesizedPiece struct {
+	Index     int
+	Audio     []byte
+	AudioData WyomingAudioData
+	Err       error
+}
+
+func synthesizePiecesWorker(serverAddr string, connectOptions ConnectOptions, pieces []speechPiece, cache *TTSCache, indexChan <-chan int, resultsChan chan<- synthesizedPiece) {
+	for index := range indexChan {
+		result := synthesizedPiece{Index: index}
+
+		w, err := ConnectWithOptions(serverAddr, connectOptions)
+		if err != nil {
+			result.Err = err
+			resultsChan <- result
+			continue
+		}
+		w.TTSCache = cache
+
+		var audio bytes.Buffer
+		result.AudioData, result.Err = w.SynthesizeAudio(pieces[index].Text, pieces[index].VoiceData, &audio)
+		result.Audio = audio.Bytes()
+		w.Disconnect()
+
+		resultsChan <- result
+	}
+}
+
+// silence returns durationMS of silent 16-bit audio described by audioData.
+func silence(audioData WyomingAudioData, durationMS int) []byte {
+	if durationMS <= 0 {
+		return nil
+	}
+	return make([]byte, audioData.Rate*durationMS/1000*audioData.Width*audioData.Channels)
+}
+
+// synthesizePieces synthesizes "workersCount" pieces at once, or one for "stdio://" servers, and writes their audio
+// to writer in order, each preceded by its silence. Every piece must be synthesized in the same audio format.
+// Pieces are read from and added to cache when it is set. Connections are made using connectOptions.
+func synthesizePieces(serverAddr string, connectOptions ConnectOptions, pieces []speechPiece, workersCount int, cache *TTSCache, writer io.Writer) (WyomingAudioData, error) {
+	var textIndexes []int
+	for i, piece := range pieces {
+		if piece.Text != "" {
+			textIndexes = append(textIndexes, i)
+		}
+	}
+	if len(textIndexes) == 0 {
+		return WyomingAudioData{}, errors.New("missing text")
+	}
+
+	indexChan := make(chan int)
+	resultsChan := make(chan synthesizedPiece, len(textIndexes))
+	stop := make(chan struct{})
+	defer close(stop)
+
+	for i := 0; i < ConnectionWorkers(serverAddr, workersCount); i += 1 {
+		go synthesizePiecesWorker(serverAddr, connectOptions, pieces, cache, indexChan, resultsChan)
+	}
+	go func() {
+		defer close(indexChan)
+		for _, i := range textIndexes {
+			select {
+			case indexChan <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// pieces are written in order as soon as every piece before them has been written. Silence can only be
+	// written once the audio format is known from the first synthesized piece.
+	var audioData WyomingAudioData
+	pending := map[int]synthesizedPiece{}
+	pendingSilenceMS := 0
+	for next := 0; next < len(pieces); {
+		if pieces[next].Text == "" {
+			pendingSilenceMS += pieces[next].SilenceBeforeMS
+			next += 1
+			continue
+		}
+
+		result, ok := pending[next]
+		if !ok {
+			result = <-resultsChan
+			if result.Err != nil {
+				return WyomingAudioData{}, result.Err
+			}
+			pending[result.Index] = result
+			continue
+		}
+		delete(pending, next)
+
+		if audioData.Rate == 0 {
+			audioData = result.AudioData
+		} else if result.AudioData.Rate != audioData.Rate || result.AudioData.Width != audioData.Width || result.AudioData.Channels != audioData.Channels {
+			return WyomingAudioData{}, errors.New("server returned audio in different formats")
+		}
+
+		if _, err := writer.Write(silence(audioData, pendingSilenceMS+pieces[next].SilenceBeforeMS)); err != nil {
+			return WyomingAudioData{}, err
+		}
+		pendingSilenceMS = 0
+		if _, err := writer.Write(result.Audio); err != nil {
+			return WyomingAudioData{}, err
+		}
+
+		next += 1
+	}
+
+	if _, err := writer.Write(silence(audioData, pendingSilenceMS)); err != nil {
+		return WyomingAudioData{}, err
+	}
+
+	return audioData, nil
+}
+
+// synthesizeToWAVFile creates a new WAV audio file located at WAVFilePath with the audio synthesize writes.
+func synthesizeToWAVFile(WAVFilePath string, synthesize func(writer io.Writer) (WyomingAudioData, error)) error {
+	tempFile, err := os.CreateTemp("", "wyoming-audio")
+	if err != nil {
+		return err
+	}
+	defer tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	// generate audio
+	audioData, err := synthesize(tempFile)
+	if err != nil {
+		return err
+	}
+
+	// convert audio
+	err = utils.ConvertPCMAudioFileToWAVFile(WAVFilePath, tempFile.Name(), int32(audioData.Rate), int16(audioData.Channels), int16(audioData.Width*8))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SynthesizeLongText splits text into paragraphs and chunks of whole sentences up to maxChars characters long,
+// synthesizes "workersCount" chunks at once, and writes the audio to writer in order. sentenceSilenceMS of silence
+// is added between the chunks of a paragraph and paragraphSilenceMS between paragraphs. Sentences are split using
+// the rules for voiceData.Language, or the language at the start of voiceData.Name if no language is set.
+// SynthesizeLongText returns a WyomingAudioData describing the audio data or an error.
+func SynthesizeLongText(serverAddr, text string, voiceData SynthesizeVoiceData, maxChars, workersCount, sentenceSilenceMS, paragraphSilenceMS int, writer io.Writer) (WyomingAudioData, error) {
+	pieces := longTextPieces(text, voiceData, maxChars, sentenceSilenceMS, paragraphSilenceMS)
+	return synthesizePieces(serverAddr, ConnectOptions{}, pieces, workersCount, nil, writer)
+}
+
+// longTextPieces splits text into the pieces synthesized by SynthesizeLongText.
+func longTextPieces(text string, voiceData SynthesizeVoiceData, maxChars, sentenceSilenceMS, paragraphSilenceMS int) []speechPiece {
+	language := voiceData.Language
+	if language == "" {
+		language = voiceData.Name
+	}
+
+	chunks := utils.SplitTextIntoChunks(text, language, maxChars)
+	pieces := make([]speechPiece, len(chunks))
+	for i, chunk := range chunks {
+		pieces[i] = speechPiece{Text: chunk.Text, VoiceData: voiceData}
+		if i > 0 {
+			pieces[i].SilenceBeforeMS = sentenceSilenceMS
+			if chunk.Paragraph != chunks[i-1].Paragraph {
+				pieces[i].SilenceBeforeMS = paragraphSilenceMS
+			}
+		}
+	}
+
+	return pieces
+}
+
+// SynthesizeLongTextToWAVFile synthesizes text using SynthesizeLongText and creates a new WAV audio file located at
+// WAVFilePath with the audio received.
+func SynthesizeLongTextToWAVFile(serverAddr, text string, voiceData SynthesizeVoiceData, maxChars, workersCount, sentenceSilenceMS, paragraphSilenceMS int, WAVFilePath string) error {
+	return synthesizeToWAVFile(WAVFilePath, func(writer io.Writer) (WyomingAudioData, error) {
+		return SynthesizeLongText(serverAddr, text, voiceData, maxChars, workersCount, sentenceSilenceMS, paragraphSilenceMS, writer)
+	})
+}
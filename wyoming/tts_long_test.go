@@ -0,0 +1,97 @@
+package wyoming
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// serveTestTTS answers conn like a Wyoming TTS service that returns the text to synthesize as its audio, with one
+// byte of audio per millisecond.
+func serveTestTTS(conn net.Conn) {
+	defer conn.Close()
+
+	w := WyomingConnection{Conn: conn}
+	reader := bufio.NewReader(conn)
+	for {
+		res, err := w.ReceiveMessageUsingReader(reader)
+		if err != nil {
+			return
+		}
+
+		switch res.Message.Type {
+		case DescribeMessageType:
+			w.SendMessageContainer(WyomingMessageContainer{Message: WyomingMessage{Type: "info"}, Data: []byte(`{"tts": [{"name": "test"}]}`)})
+		case SynthesizeMessageType:
+			var synthesizeData SynthesizeData
+			messageData, _ := json.Marshal(res.Message.Data)
+			json.Unmarshal(messageData, &synthesizeData)
+
+			audioData, _ := json.Marshal(WyomingAudioData{Rate: 1000, Width: 1, Channels: 1})
+			w.SendMessageContainer(WyomingMessageContainer{Message: WyomingMessage{Type: AudioChunkMessageType}, Data: audioData, Payload: []byte(synthesizeData.Text)})
+			w.SendMessage(WyomingMessage{Type: AudioStopMessageType})
+		}
+	}
+}
+
+// useTestTTS registers the "ttstest://" transport connecting to serveTestTTS until the test ends.
+func useTestTTS(t *testing.T) {
+	Transports["ttstest"] = func(serverAddr string, options ConnectOptions) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveTestTTS(server)
+		return client, nil
+	}
+	t.Cleanup(func() { delete(Transports, "ttstest") })
+}
+
+// testSilence returns the silence serveTestTTS audio has for durationMS.
+func testSilence(durationMS int) string {
+	return strings.Repeat("\x00", durationMS)
+}
+
+func TestLongTextPieces(t *testing.T) {
+	voiceData := SynthesizeVoiceData{Name: "en_US-amy"}
+	got := longTextPieces("One two. Three four.\n\nFive.", voiceData, 0, 100, 300)
+
+	want := []speechPiece{
+		{Text: "One two.", VoiceData: voiceData},
+		{Text: "Three four.", VoiceData: voiceData, SilenceBeforeMS: 100},
+		{Text: "Five.", VoiceData: voiceData, SilenceBeforeMS: 300},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("longTextPieces = %+v, want %+v", got, want)
+	}
+}
+
+func TestSynthesizeLongText(t *testing.T) {
+	useTestTTS(t)
+
+	tests := []struct {
+		workersCount int
+		maxChars     int
+		want         string
+	}{
+		{workersCount: 1, maxChars: 0, want: "One two." + testSilence(100) + "Three four." + testSilence(300) + "Five."},
+		{workersCount: 3, maxChars: 0, want: "One two." + testSilence(100) + "Three four." + testSilence(300) + "Five."},
+		{workersCount: 2, maxChars: 40, want: "One two. Three four." + testSilence(300) + "Five."},
+	}
+
+	for _, test := range tests {
+		var audio bytes.Buffer
+		audioData, err := SynthesizeLongText("ttstest://", "One two. Three four.\n\nFive.", SynthesizeVoiceData{}, test.maxChars, test.workersCount, 100, 300, &audio)
+		if err != nil {
+			t.Errorf("SynthesizeLongText with %d workers returned error %v", test.workersCount, err)
+			continue
+		}
+		if audioData.Rate != 1000 {
+			t.Errorf("SynthesizeLongText with %d workers returned audio data %+v", test.workersCount, audioData)
+		}
+		if audio.String() != test.want {
+			t.Errorf("SynthesizeLongText with %d workers and %d characters wrote %q, want %q", test.workersCount, test.maxChars, audio.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package wyoming
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+var VoiceStartedMessageType string = "voice-started"
+var VoiceStoppedMessageType string = "voice-stopped"
+
+type VoiceEventData struct {
+	Timestamp *int `json:"timestamp,omitempty"`
+}
+
+type voiceEvent struct {
+	started     bool
+	timestampMS int
+}
+
+// RemoteVADSegmenter splits audio into segments using the "voice-started" and "voice-stopped" events sent by a
+// Wyoming VAD service at ServerAddr. Audio is streamed to the service as it is read and a segment is returned
+// once the service reports that voice has stopped. Voice shorter than MinSoundDurationMS is dropped and voice
+// separated by less than MinSilenceDurationMS of silence is joined into one segment. Up to PreRollMS of the audio
+// before the voice and PostRollMS after it are included in each segment. If MaxSegmentMS is set, voice longer than
+// that is split into segments of MaxSegmentMS once it stops, since the events may arrive well after the audio was
+// sent. RemoteVADSegmenter implements utils.AudioSegmenter.
+type RemoteVADSegmenter struct {
+	ServerAddr string
+	// ConnectOptions are used to connect to ServerAddr.
+	ConnectOptions ConnectOptions
+	AudioData      WyomingAudioData
+	// ChunkMS is the length of each "audio-chunk" sent to the service.
+	ChunkMS int
+	// KeepMS is how much audio is kept before the current position while no voice is detected, allowing
+	// "voice-started" events with a timestamp in the past. Audio from files is sent faster than real time, so
+	// this needs to cover how far behind the service may fall.
+	KeepMS int
+	// StopTimeout is how long to wait for the remaining voice events after the audio ends.
+	StopTimeout          time.Duration
+	MinSoundDurationMS   int
+	MinSilenceDurationMS int
+	PreRollMS            int
+	PostRollMS           int
+	MaxSegmentMS         int
+
+	conn          *WyomingConnection
+	events        chan voiceEvent
+	done          chan struct{}
+	buff          []byte
+	buffStartMS   int
+	sentBytes     int
+	inVoice       bool
+	voiceStartMS  int
+	stopPending   bool
+	voiceStopMS   int
+	pending       []utils.AudioEvent
+	finished      bool
+	receiverError error
+}
+
+// NewRemoteVADSegmenter returns a RemoteVADSegmenter for audio described by audioData using the VAD service at serverAddr.
+func NewRemoteVADSegmenter(serverAddr string, audioData WyomingAudioData, chunkMS int) *RemoteVADSegmenter {
+	return &RemoteVADSegmenter{
+		ServerAddr:  serverAddr,
+		AudioData:   audioData,
+		ChunkMS:     chunkMS,
+		KeepMS:      30000,
+		StopTimeout: time.Second,
+	}
+}
+
+// bytesPerMS returns the number of bytes of audio per millisecond.
+func (r *RemoteVADSegmenter) bytesPerMS() float64 {
+	return float64(r.AudioData.Rate*r.AudioData.Width*r.AudioData.Channels) / 1000
+}
+
+// byteOffset converts timeMS to an offset into the audio aligned to a full frame.
+func (r *RemoteVADSegmenter) byteOffset(timeMS int) int {
+	frameSize := r.AudioData.Width * r.AudioData.Channels
+	offset := int(float64(timeMS) * r.bytesPerMS())
+	return offset - offset%frameSize
+}
+
+// sentMS returns the duration of the audio sent to the service so far.
+func (r *RemoteVADSegmenter) sentMS() int {
+	return int(float64(r.sentBytes) / r.bytesPerMS())
+}
+
+// start connects to the VAD service, begins the audio stream, and starts receiving voice events.
+func (r *RemoteVADSegmenter) start() error {
+	if r.AudioData.Rate <= 0 || r.AudioData.Width <= 0 || r.AudioData.Channels <= 0 {
+		return errors.New("invalid audio data")
+	}
+	if r.ChunkMS <= 0 {
+		return errors.New("invalid chunk size")
+	}
+
+	w, err := ConnectWithOptions(r.ServerAddr, r.ConnectOptions)
+	if err != nil {
+		return err
+	}
+
+	err = w.SendMessage(WyomingMessage{Type: AudioStartMessageType, Data: r.AudioData})
+	if err != nil {
+		w.Disconnect()
+		return err
+	}
+
+	r.conn = &w
+	r.events = make(chan voiceEvent, 16)
+	r.done = make(chan struct{})
+	go r.receiveEvents(r.conn)
+
+	return nil
+}
+
+// receiveEvents reads messages from the VAD service on conn and forwards voice events until the connection is
+// closed. conn is passed in because stop clears r.conn while receiveEvents is running.
+func (r *RemoteVADSegmenter) receiveEvents(conn *WyomingConnection) {
+	defer close(r.events)
+	reader := bufio.NewReader(conn.Conn)
+
+	for {
+		res, err := conn.ReceiveMessageUsingReader(reader)
+		if err != nil {
+			r.receiverError = err
+			return
+		}
+
+		if res.Message.Type != VoiceStartedMessageType && res.Message.Type != VoiceStoppedMessageType {
+			continue
+		}
+
+		event := voiceEvent{started: res.Message.Type == VoiceStartedMessageType, timestampMS: -1}
+		if len(res.Data) > 0 {
+			var eventData VoiceEventData
+			err = json.Unmarshal(res.Data, &eventData)
+			if err != nil {
+				r.receiverError = err
+				return
+			}
+			if eventData.Timestamp != nil {
+				event.timestampMS = *eventData.Timestamp
+			}
+		}
+
+		select {
+		case r.events <- event:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// stop ends the audio stream and disconnects from the VAD service.
+func (r *RemoteVADSegmenter) stop() {
+	r.finished = true
+	if r.conn == nil {
+		return
+	}
+
+	close(r.done)
+	r.conn.Disconnect()
+	r.conn = nil
+}
+
+// handleEvent updates the voice state using event. The previous voice is queued when voice starts again after at
+// least MinSilenceDurationMS of silence.
+func (r *RemoteVADSegmenter) handleEvent(event voiceEvent) {
+	timestampMS := event.timestampMS
+	if timestampMS < 0 {
+		timestampMS = r.sentMS()
+	}
+	timestampMS = min(max(timestampMS, r.buffStartMS), r.sentMS())
+
+	if event.started {
+		if r.inVoice {
+			return
+		}
+		r.inVoice = true
+
+		if r.stopPending {
+			if timestampMS-r.voiceStopMS < r.MinSilenceDurationMS {
+				// the silence was too short, continue the voice
+				r.stopPending = false
+				return
+			}
+			r.queueVoice()
+			timestampMS = max(timestampMS, r.buffStartMS)
+		}
+
+		r.voiceStartMS = timestampMS
+		return
+	}
+
+	if !r.inVoice {
+		return
+	}
+	r.inVoice = false
+	r.stopPending = true
+	r.voiceStopMS = max(timestampMS, r.voiceStartMS)
+}
+
+// queueVoice queues the voice between voiceStartMS and voiceStopMS with the pre-roll and post-roll, split into
+// segments of at most MaxSegmentMS. Voice shorter than MinSoundDurationMS is dropped.
+func (r *RemoteVADSegmenter) queueVoice() {
+	r.stopPending = false
+	if r.voiceStopMS-r.voiceStartMS < r.MinSoundDurationMS {
+		return
+	}
+
+	startMS := max(r.voiceStartMS-r.PreRollMS, r.buffStartMS)
+	continued := false
+	for r.MaxSegmentMS > 0 && r.voiceStopMS-r.voiceStartMS > r.MaxSegmentMS {
+		splitMS := r.voiceStartMS + r.MaxSegmentMS
+		audioEvent := r.cutSegment(startMS, splitMS)
+		audioEvent.Split = true
+		audioEvent.Continued = continued
+		r.pending = append(r.pending, audioEvent)
+
+		startMS = splitMS
+		r.voiceStartMS = splitMS
+		continued = true
+	}
+
+	audioEvent := r.cutSegment(startMS, min(r.voiceStopMS+r.PostRollMS, r.sentMS()))
+	audioEvent.Continued = continued
+	r.pending = append(r.pending, audioEvent)
+}
+
+// queueStoppedVoice queues the voice once the silence after it has reached MinSilenceDurationMS.
+func (r *RemoteVADSegmenter) queueStoppedVoice() {
+	if r.stopPending && r.sentMS()-r.voiceStopMS >= r.MinSilenceDurationMS {
+		r.queueVoice()
+	}
+}
+
+// cutSegment returns the audio between startMS and endMS and drops the buffered audio before endMS.
+func (r *RemoteVADSegmenter) cutSegment(startMS, endMS int) utils.AudioEvent {
+	bufferOffset := r.byteOffset(r.buffStartMS)
+	startOffset := min(max(r.byteOffset(startMS)-bufferOffset, 0), len(r.buff))
+	endOffset := min(max(r.byteOffset(endMS)-bufferOffset, startOffset), len(r.buff))
+
+	audioEvent := utils.AudioEvent{
+		Start: time.Millisecond * time.Duration(startMS),
+		End:   time.Millisecond * time.Duration(endMS),
+	}
+	audioEvent.SoundBuff.Write(r.buff[startOffset:endOffset])
+
+	r.buff = append([]byte(nil), r.buff[endOffset:]...)
+	r.buffStartMS = endMS
+
+	return audioEvent
+}
+
+// trimBuffer drops audio older than KeepMS while no voice is detected.
+func (r *RemoteVADSegmenter) trimBuffer() {
+	if r.inVoice || r.stopPending {
+		return
+	}
+
+	keepStartMS := r.sentMS() - r.KeepMS
+	if keepStartMS <= r.buffStartMS {
+		return
+	}
+
+	dropBytes := min(r.byteOffset(keepStartMS)-r.byteOffset(r.buffStartMS), len(r.buff))
+	r.buff = append([]byte(nil), r.buff[dropBytes:]...)
+	r.buffStartMS = keepStartMS
+}
+
+// finish ends the audio stream and queues the segments reported by the VAD service before StopTimeout. If voice
+// has not stopped by then, the remaining audio is queued as the final segment.
+func (r *RemoteVADSegmenter) finish() error {
+	defer r.stop()
+
+	err := r.conn.SendMessage(WyomingMessage{Type: AudioStopMessageType, Data: r.AudioData})
+	if err != nil {
+		return err
+	}
+
+	timeout := time.After(r.StopTimeout)
+	for waiting := true; waiting; {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				waiting = false
+				break
+			}
+			r.handleEvent(event)
+			r.queueStoppedVoice()
+		case <-timeout:
+			waiting = false
+		}
+	}
+
+	if r.inVoice {
+		r.inVoice = false
+		r.stopPending = true
+		r.voiceStopMS = r.sentMS()
+	}
+	if r.stopPending {
+		r.queueVoice()
+	}
+
+	return nil
+}
+
+// NextAudioGroup reads from reader and streams the audio to the VAD service until the next segment of voice is
+// detected. io.EOF is returned once reader and all detected segments have been consumed.
+func (r *RemoteVADSegmenter) NextAudioGroup(reader io.Reader) (utils.AudioEvent, error) {
+	if len(r.pending) > 0 {
+		audioEvent := r.pending[0]
+		r.pending = r.pending[1:]
+		return audioEvent, nil
+	}
+	if r.finished {
+		return utils.AudioEvent{}, io.EOF
+	}
+	if r.conn == nil {
+		err := r.start()
+		if err != nil {
+			return utils.AudioEvent{}, err
+		}
+	}
+
+	chunk := make([]byte, max(r.byteOffset(r.ChunkMS), r.AudioData.Width*r.AudioData.Channels))
+	for {
+		// handle events received so far
+		for pending := true; pending; {
+			select {
+			case event, ok := <-r.events:
+				if !ok {
+					r.stop()
+					if r.receiverError != nil {
+						return utils.AudioEvent{}, r.receiverError
+					}
+					return utils.AudioEvent{}, errors.New("VAD service closed the connection")
+				}
+				r.handleEvent(event)
+			default:
+				pending = false
+			}
+		}
+		r.queueStoppedVoice()
+		if len(r.pending) > 0 {
+			return r.NextAudioGroup(reader)
+		}
+
+		n, err := io.ReadFull(reader, chunk)
+		if n > 0 {
+			r.buff = append(r.buff, chunk[:n]...)
+			r.sentBytes += n
+
+			sendErr := r.conn.SendMessageContainer(
+				WyomingMessageContainer{
+					Message: WyomingMessage{Type: AudioChunkMessageType, Data: r.AudioData},
+					Payload: bytes.Clone(chunk[:n]),
+				},
+			)
+			if sendErr != nil {
+				r.stop()
+				return utils.AudioEvent{}, sendErr
+			}
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				r.stop()
+				return utils.AudioEvent{}, err
+			}
+
+			err = r.finish()
+			if err != nil {
+				return utils.AudioEvent{}, err
+			}
+			return r.NextAudioGroup(reader)
+		}
+
+		r.trimBuffer()
+	}
+}
@@ -0,0 +1,226 @@
+package wyoming
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testVoiceSegment is the part of an utils.AudioEvent compared by the RemoteVADSegmenter tests.
+type testVoiceSegment struct {
+	startMS   int
+	endMS     int
+	split     bool
+	continued bool
+}
+
+// testAudioData is mono 16-bit audio with 32 bytes per millisecond.
+var testAudioData = WyomingAudioData{Rate: 16000, Width: 2, Channels: 1}
+
+// serveTestVAD answers conn like a Wyoming VAD service that detects voice between the start and end of each of voice,
+// given in milliseconds. The events are sent once the audio they refer to has been received.
+func serveTestVAD(conn net.Conn, voice [][2]int) {
+	defer conn.Close()
+
+	w := WyomingConnection{Conn: conn}
+	reader := bufio.NewReader(conn)
+	receivedMS := 0
+	for {
+		res, err := w.ReceiveMessageUsingReader(reader)
+		if err != nil {
+			return
+		}
+
+		switch res.Message.Type {
+		case DescribeMessageType:
+			w.SendMessageContainer(WyomingMessageContainer{Message: WyomingMessage{Type: "info"}, Data: []byte("{}")})
+		case AudioChunkMessageType:
+			previousMS := receivedMS
+			receivedMS += len(res.Payload) / 32
+			for _, times := range voice {
+				for i, eventType := range []string{VoiceStartedMessageType, VoiceStoppedMessageType} {
+					if times[i] > previousMS && times[i] <= receivedMS {
+						data, _ := json.Marshal(VoiceEventData{Timestamp: &times[i]})
+						w.SendMessageContainer(WyomingMessageContainer{Message: WyomingMessage{Type: eventType}, Data: data})
+					}
+				}
+			}
+		case AudioStopMessageType:
+			return
+		}
+	}
+}
+
+// readVoiceSegments calls segmenter until it returns io.EOF and checks that the audio of each segment matches its
+// times.
+func readVoiceSegments(t *testing.T, segmenter *RemoteVADSegmenter, audio []byte) []testVoiceSegment {
+	t.Helper()
+
+	var segments []testVoiceSegment
+	reader := bytes.NewReader(audio)
+	for {
+		audioEvent, err := segmenter.NextAudioGroup(reader)
+		if errors.Is(err, io.EOF) {
+			return segments
+		}
+		if err != nil {
+			t.Fatalf("NextAudioGroup returned error %v", err)
+		}
+
+		segment := testVoiceSegment{
+			startMS:   int(audioEvent.Start.Milliseconds()),
+			endMS:     int(audioEvent.End.Milliseconds()),
+			split:     audioEvent.Split,
+			continued: audioEvent.Continued,
+		}
+		if wantBytes := (segment.endMS - segment.startMS) * 32; audioEvent.SoundBuff.Len() != wantBytes {
+			t.Errorf("segment %+v has %d bytes of audio, want %d", segment, audioEvent.SoundBuff.Len(), wantBytes)
+		}
+		segments = append(segments, segment)
+	}
+}
+
+func TestRemoteVADSegmenterStream(t *testing.T) {
+	voice := [][2]int{{500, 1000}, {1400, 1800}}
+	Transports["vadtest"] = func(serverAddr string, options ConnectOptions) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveTestVAD(server, voice)
+		return client, nil
+	}
+	defer delete(Transports, "vadtest")
+
+	segmenter := NewRemoteVADSegmenter("vadtest://", testAudioData, 100)
+	segmenter.MinSoundDurationMS = 100
+	segmenter.MinSilenceDurationMS = 200
+	got := readVoiceSegments(t, segmenter, make([]byte, 2000*32))
+
+	want := []testVoiceSegment{{startMS: 500, endMS: 1000}, {startMS: 1400, endMS: 1800}}
+	if len(got) != len(want) {
+		t.Fatalf("got segments %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("segment %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRemoteVADSegmenterEvents(t *testing.T) {
+	started := func(timestampMS int) voiceEvent { return voiceEvent{started: true, timestampMS: timestampMS} }
+	stopped := func(timestampMS int) voiceEvent { return voiceEvent{started: false, timestampMS: timestampMS} }
+
+	tests := []struct {
+		name      string
+		segmenter RemoteVADSegmenter
+		events    []voiceEvent
+		want      []testVoiceSegment
+	}{
+		{
+			name:   "voice",
+			events: []voiceEvent{started(500), stopped(1000)},
+			want:   []testVoiceSegment{{startMS: 500, endMS: 1000}},
+		},
+		{
+			name:   "short voice",
+			events: []voiceEvent{started(500), stopped(550)},
+			want:   nil,
+		},
+		{
+			name:   "short silence",
+			events: []voiceEvent{started(500), stopped(1000), started(1100), stopped(1500)},
+			want:   []testVoiceSegment{{startMS: 500, endMS: 1500}},
+		},
+		{
+			name:   "separate voice",
+			events: []voiceEvent{started(500), stopped(1000), started(1400), stopped(1800)},
+			want:   []testVoiceSegment{{startMS: 500, endMS: 1000}, {startMS: 1400, endMS: 1800}},
+		},
+		{
+			name:   "voice until the end",
+			events: []voiceEvent{started(1500)},
+			want:   []testVoiceSegment{{startMS: 1500, endMS: 2000}},
+		},
+		{
+			name:   "repeated events",
+			events: []voiceEvent{started(500), started(600), stopped(1000), stopped(1100)},
+			want:   []testVoiceSegment{{startMS: 500, endMS: 1000}},
+		},
+		{
+			name:   "timestamp after the audio",
+			events: []voiceEvent{started(500), stopped(2500)},
+			want:   []testVoiceSegment{{startMS: 500, endMS: 2000}},
+		},
+		{
+			name:      "pre-roll and post-roll",
+			segmenter: RemoteVADSegmenter{PreRollMS: 100, PostRollMS: 50},
+			events:    []voiceEvent{started(500), stopped(1000), started(1400), stopped(1800)},
+			want:      []testVoiceSegment{{startMS: 400, endMS: 1050}, {startMS: 1300, endMS: 1850}},
+		},
+		{
+			name:      "padding limited by the audio",
+			segmenter: RemoteVADSegmenter{PreRollMS: 100, PostRollMS: 300},
+			events:    []voiceEvent{started(500), stopped(1000), started(1400), stopped(1800)},
+			want:      []testVoiceSegment{{startMS: 400, endMS: 1300}, {startMS: 1300, endMS: 2000}},
+		},
+		{
+			name:      "maximum segment length",
+			segmenter: RemoteVADSegmenter{PreRollMS: 100, PostRollMS: 50, MaxSegmentMS: 300},
+			events:    []voiceEvent{started(500), stopped(1200)},
+			want: []testVoiceSegment{
+				{startMS: 400, endMS: 800, split: true},
+				{startMS: 800, endMS: 1100, split: true, continued: true},
+				{startMS: 1100, endMS: 1250, continued: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		segmenter := test.segmenter
+		segmenter.AudioData = testAudioData
+		segmenter.MinSoundDurationMS = 100
+		segmenter.MinSilenceDurationMS = 200
+		segmenter.buff = make([]byte, 2000*32)
+
+		// each event arrives once the audio up to its timestamp has been sent
+		for _, event := range test.events {
+			segmenter.sentBytes = max(segmenter.sentBytes, segmenter.byteOffset(min(event.timestampMS, 2000)))
+			segmenter.handleEvent(event)
+			segmenter.queueStoppedVoice()
+		}
+		// end the voice at the end of the audio like finish
+		segmenter.sentBytes = segmenter.byteOffset(2000)
+		segmenter.handleEvent(stopped(-1))
+		if segmenter.stopPending {
+			segmenter.queueVoice()
+		}
+
+		var got []testVoiceSegment
+		for _, audioEvent := range segmenter.pending {
+			segment := testVoiceSegment{
+				startMS:   int(audioEvent.Start / time.Millisecond),
+				endMS:     int(audioEvent.End / time.Millisecond),
+				split:     audioEvent.Split,
+				continued: audioEvent.Continued,
+			}
+			if wantBytes := (segment.endMS - segment.startMS) * 32; audioEvent.SoundBuff.Len() != wantBytes {
+				t.Errorf("%s: segment %+v has %d bytes of audio, want %d", test.name, segment, audioEvent.SoundBuff.Len(), wantBytes)
+			}
+			got = append(got, segment)
+		}
+
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got segments %+v, want %+v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: segment %d = %+v, want %+v", test.name, i, got[i], test.want[i])
+			}
+		}
+	}
+}
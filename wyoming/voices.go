@@ -0,0 +1,129 @@
+package wyoming
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/john-pettigrew/wyoming-cli/utils"
+)
+
+// TTSVoice describes a voice reported by a Wyoming server.
+type TTSVoice struct {
+	Service     string
+	Name        string
+	Languages   []string
+	Speakers    []string
+	Description string
+}
+
+// ListVoices returns the voices of every TTS service reported by the Wyoming server.
+func (w *WyomingConnection) ListVoices() []TTSVoice {
+	var voices []TTSVoice
+	for _, service := range w.VoiceServices.TTS {
+		for _, voiceInfo := range service.Voices {
+			voice := TTSVoice{Service: service.Name, Name: voiceInfo.Name, Languages: voiceInfo.Languages, Description: voiceInfo.Description}
+			for _, speaker := range voiceInfo.Speakers {
+				voice.Speakers = append(voice.Speakers, speaker.Name)
+			}
+			voices = append(voices, voice)
+		}
+	}
+
+	return voices
+}
+
+// sameLanguage returns true if a and b name the same language, ignoring case and "_" or "-" separators.
+func sameLanguage(a, b string) bool {
+	return strings.EqualFold(strings.ReplaceAll(a, "-", "_"), strings.ReplaceAll(b, "-", "_"))
+}
+
+// unknownNameError returns an error for an unknown name of kind, suggesting close matches from candidates.
+func unknownNameError(kind, name string, candidates []string) error {
+	suggestions := utils.SuggestNames(name, candidates)
+	if len(suggestions) > 0 {
+		return fmt.Errorf("unknown %s %q, did you mean %q?", kind, name, suggestions[0])
+	}
+	if len(candidates) > 0 && len(candidates) <= 10 {
+		return fmt.Errorf("unknown %s %q, available: %s", kind, name, strings.Join(candidates, ", "))
+	}
+	return fmt.Errorf("unknown %s %q", kind, name)
+}
+
+// ValidateVoice checks that the voice, speaker and language requested in voiceData are reported by the Wyoming
+// server. Errors suggest the closest names for misspelled values. ValidateVoice returns nil if the server does
+// not report any voices.
+func (w *WyomingConnection) ValidateVoice(voiceData SynthesizeVoiceData) error {
+	voices := w.ListVoices()
+	if len(voices) == 0 {
+		return nil
+	}
+
+	candidates := voices
+	if voiceData.Name != "" {
+		candidates = nil
+		var names []string
+		for _, voice := range voices {
+			names = append(names, voice.Name)
+			if voice.Name == voiceData.Name {
+				candidates = append(candidates, voice)
+			}
+		}
+		if len(candidates) == 0 {
+			return unknownNameError("voice", voiceData.Name, names)
+		}
+	}
+
+	if voiceData.Language != "" {
+		var languages []string
+		var matching []TTSVoice
+		for _, voice := range candidates {
+			for _, language := range voice.Languages {
+				languages = append(languages, language)
+				if sameLanguage(language, voiceData.Language) {
+					matching = append(matching, voice)
+					break
+				}
+			}
+		}
+		// voices that do not report their languages can not be checked
+		if len(languages) > 0 {
+			if len(matching) == 0 {
+				return unknownNameError("language", voiceData.Language, uniqueStrings(languages))
+			}
+			candidates = matching
+		}
+	}
+
+	if voiceData.Speaker != "" {
+		var speakers []string
+		for _, voice := range candidates {
+			for _, speaker := range voice.Speakers {
+				if speaker == voiceData.Speaker {
+					return nil
+				}
+				speakers = append(speakers, speaker)
+			}
+		}
+		if len(speakers) == 0 {
+			if voiceData.Name != "" {
+				return fmt.Errorf("voice %q does not have any speakers", voiceData.Name)
+			}
+			return fmt.Errorf("no voice has speaker %q", voiceData.Speaker)
+		}
+		return unknownNameError("speaker", voiceData.Speaker, uniqueStrings(speakers))
+	}
+
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}